@@ -0,0 +1,269 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Supported archive formats
+const (
+	FormatZip   = "zip"
+	FormatTarGz = "tar.gz"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported archive format")
+	ErrTooManyEntries    = errors.New("archive contains too many entries")
+	ErrTooLarge          = errors.New("archive expands beyond the allowed size")
+	ErrSuspiciousRatio   = errors.New("archive compression ratio is suspiciously high")
+	ErrUnsafePath        = errors.New("entry path escapes the target directory")
+	ErrUnsupportedEntry  = errors.New("entry type is not supported")
+)
+
+// ratioCheckThreshold is the uncompressed size below which ratios are not checked;
+// tiny text files legitimately compress very well
+const ratioCheckThreshold = 1 << 20 // 1MB
+
+// Limits bounds the resources an extraction may consume
+type Limits struct {
+	MaxEntries   int
+	MaxTotalSize int64
+	MaxRatio     float64
+}
+
+// Entry is a single regular file or directory inside an archive
+type Entry struct {
+	// Name is the cleaned slash-separated path inside the archive
+	Name  string
+	IsDir bool
+	// Size is the declared uncompressed size
+	Size int64
+}
+
+// WalkFunc is called for each archive entry. For entries that cannot be
+// extracted (unsafe paths, links, devices) r is nil and entryErr explains why.
+// Returning an error aborts the walk.
+type WalkFunc func(entry Entry, r io.Reader, entryErr error) error
+
+// DetectFormat determines the archive format from a filename
+func DetectFormat(filename string) (string, error) {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// CleanName normalizes an entry name and rejects absolute or escaping paths
+func CleanName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", ErrUnsafePath
+	}
+	for _, seg := range strings.Split(name, "/") {
+		if seg == ".." {
+			return "", ErrUnsafePath
+		}
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == "" {
+		return "", ErrUnsafePath
+	}
+	return cleaned, nil
+}
+
+// Walk iterates over the entries of an archive, calling fn for each.
+// The reader passed to fn fails once the entry exceeds its declared size or the
+// archive exceeds its limits, and is only valid during the call.
+func Walk(r io.ReaderAt, size int64, format string, limits Limits, fn WalkFunc) error {
+	switch format {
+	case FormatZip:
+		return walkZip(r, size, limits, fn)
+	case FormatTarGz:
+		return walkTarGz(io.NewSectionReader(r, 0, size), limits, fn)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+// budget tracks entry count, total uncompressed size and compression ratio across an archive
+type budget struct {
+	limits     Limits
+	entries    int
+	total      int64
+	compressed *countingReader // set for streaming formats where the ratio is checked while reading
+}
+
+func (b *budget) addEntry() error {
+	b.entries++
+	if b.limits.MaxEntries > 0 && b.entries > b.limits.MaxEntries {
+		return fmt.Errorf("%w (limit %d)", ErrTooManyEntries, b.limits.MaxEntries)
+	}
+	return nil
+}
+
+// limitedReader enforces the declared entry size and the archive-wide size budget
+// on the bytes actually produced by decompression
+type limitedReader struct {
+	r        io.Reader
+	declared int64
+	read     int64
+	budget   *budget
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	l.budget.total += int64(n)
+	if l.read > l.declared {
+		return n, fmt.Errorf("%w: entry is larger than declared", ErrTooLarge)
+	}
+	if l.budget.limits.MaxTotalSize > 0 && l.budget.total > l.budget.limits.MaxTotalSize {
+		return n, fmt.Errorf("%w (limit %d bytes)", ErrTooLarge, l.budget.limits.MaxTotalSize)
+	}
+	if c := l.budget.compressed; c != nil && l.budget.limits.MaxRatio > 0 && l.budget.total > ratioCheckThreshold &&
+		float64(l.budget.total)/float64(max(c.n, 1)) > l.budget.limits.MaxRatio {
+		return n, ErrSuspiciousRatio
+	}
+	return n, err
+}
+
+func walkZip(r io.ReaderAt, size int64, limits Limits, fn WalkFunc) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	// The central directory is read up front, so count and size limits can be checked before extracting anything
+	b := &budget{limits: limits}
+	var declaredTotal uint64
+	for _, f := range zr.File {
+		if err := b.addEntry(); err != nil {
+			return err
+		}
+		declaredTotal += f.UncompressedSize64
+	}
+	if limits.MaxTotalSize > 0 && declaredTotal > uint64(limits.MaxTotalSize) {
+		return fmt.Errorf("%w (limit %d bytes)", ErrTooLarge, limits.MaxTotalSize)
+	}
+
+	for _, f := range zr.File {
+		if limits.MaxRatio > 0 && f.UncompressedSize64 > ratioCheckThreshold {
+			if f.CompressedSize64 == 0 || float64(f.UncompressedSize64)/float64(f.CompressedSize64) > limits.MaxRatio {
+				return fmt.Errorf("%w: %s", ErrSuspiciousRatio, f.Name)
+			}
+		}
+
+		name, err := CleanName(f.Name)
+		if err != nil {
+			if err := fn(Entry{Name: f.Name}, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		mode := f.Mode()
+		entry := Entry{Name: name, IsDir: mode.IsDir(), Size: int64(f.UncompressedSize64)}
+		switch {
+		case entry.IsDir:
+			err = fn(entry, nil, nil)
+		case !mode.IsRegular():
+			err = fn(entry, nil, ErrUnsupportedEntry)
+		default:
+			var rc io.ReadCloser
+			if rc, err = f.Open(); err != nil {
+				return err
+			}
+			err = fn(entry, &limitedReader{r: rc, declared: entry.Size, budget: b}, nil)
+			rc.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countingReader counts bytes consumed from the compressed stream
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func walkTarGz(r io.Reader, limits Limits, fn WalkFunc) error {
+	compressed := &countingReader{r: r}
+	gz, err := gzip.NewReader(compressed)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	b := &budget{limits: limits, compressed: compressed}
+	var declaredTotal int64
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// PAX global headers carry metadata only
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		if err := b.addEntry(); err != nil {
+			return err
+		}
+
+		// The tar reader guarantees an entry yields exactly its declared size,
+		// so skipped entries can be charged against the budget up front
+		declaredTotal += hdr.Size
+		if limits.MaxTotalSize > 0 && declaredTotal > limits.MaxTotalSize {
+			return fmt.Errorf("%w (limit %d bytes)", ErrTooLarge, limits.MaxTotalSize)
+		}
+
+		name, err := CleanName(hdr.Name)
+		if err != nil {
+			if err := fn(Entry{Name: hdr.Name}, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		entry := Entry{Name: name, Size: hdr.Size}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entry.IsDir = true
+			err = fn(entry, nil, nil)
+		case tar.TypeReg:
+			err = fn(entry, &limitedReader{r: tr, declared: hdr.Size, budget: b}, nil)
+		default:
+			// Symlinks, hard links and devices are never extracted
+			err = fn(entry, nil, ErrUnsupportedEntry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
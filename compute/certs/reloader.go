@@ -0,0 +1,168 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often the certificate files are checked for changes
+const pollInterval = 10 * time.Second
+
+// Reloader serves a certificate and optional client CA bundle loaded from files, and
+// picks up replacements (such as a renewal by certbot or cert-manager) without a restart
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	stop     chan struct{}
+	wg       sync.WaitGroup
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	stamp     string
+}
+
+// NewReloader loads the certificate and key, and the client CA bundle when caFile is
+// set, then watches them for changes. Loading errors at startup are returned; later
+// ones are logged and the previous certificate stays in use.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		stop:     make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	r.wg.Add(1)
+	go r.loop()
+	return r, nil
+}
+
+// Reload reads the files again and swaps in what they contain, unless loading fails
+func (r *Reloader) Reload() error {
+	stamp := r.fileStamp()
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("parse certificate: %w", err)
+		}
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("load client CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("load client CA bundle: no PEM certificates found in " + r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.stamp = stamp
+	r.mu.Unlock()
+
+	slog.Info("Loaded TLS certificate", "subject", cert.Leaf.Subject.String(),
+		"dns_names", cert.Leaf.DNSNames, "not_after", cert.Leaf.NotAfter.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// TLSConfig returns a server configuration that always presents the current certificate
+// and verifies client certificates against the current CA bundle with clientAuth
+func (r *Reloader) TLSConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		// Set on the base configuration too, since a server without a certificate
+		// source there refuses to start even though GetConfigForClient supplies one
+		GetCertificate: r.getCertificate,
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		c := base.Clone()
+		c.GetConfigForClient = nil
+		if r.clientCAs != nil {
+			c.ClientCAs = r.clientCAs
+			c.ClientAuth = clientAuth
+		}
+		return c, nil
+	}
+	return base
+}
+
+// getCertificate returns the current certificate
+func (r *Reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Stop ends the file watcher
+func (r *Reloader) Stop() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+// loop reloads the files whenever their size or modification time changes
+func (r *Reloader) loop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.RLock()
+		changed := r.fileStamp() != r.stamp
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			// Renewals often write the certificate and key separately; a mismatched
+			// pair is retried on the next tick
+			slog.Error("Failed to reload TLS certificate, keeping the current one", "error", err)
+		}
+	}
+}
+
+// fileStamp summarises the size and modification time of the watched files. Stat follows
+// symlinks, so Kubernetes secret volumes that swap a symlink are noticed too.
+func (r *Reloader) fileStamp() string {
+	stamp := ""
+	for _, name := range []string{r.certFile, r.keyFile, r.caFile} {
+		if name == "" {
+			continue
+		}
+		if info, err := os.Stat(name); err == nil {
+			stamp += fmt.Sprintf("%d:%d;", info.Size(), info.ModTime().UnixNano())
+		} else {
+			stamp += "missing;"
+		}
+	}
+	return stamp
+}
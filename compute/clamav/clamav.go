@@ -0,0 +1,173 @@
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnavailable is returned when clamd cannot be reached
+	ErrUnavailable = errors.New("virus scanner unavailable")
+	// ErrScanFailed is returned when clamd could not scan the stream, e.g. because it exceeds StreamMaxLength
+	ErrScanFailed = errors.New("virus scan failed")
+)
+
+// chunkSize is the INSTREAM chunk size; clamd accepts chunks of any size up to StreamMaxLength
+const chunkSize = 64 * 1024
+
+// maxReplyLen bounds the reply read from clamd
+const maxReplyLen = 4096
+
+// Result is the verdict for a scanned stream
+type Result struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Client talks to clamd over TCP or a unix socket
+type Client struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// New creates a client for an address of the form tcp://host:port,
+// unix:///path/to/clamd.sock or a bare socket path
+func New(address string, timeout time.Duration) (*Client, error) {
+	c := &Client{timeout: timeout}
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		c.network, c.address = "tcp", strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		c.network, c.address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "/"):
+		c.network, c.address = "unix", address
+	default:
+		return nil, fmt.Errorf("clamd address must start with tcp://, unix:// or /: %q", address)
+	}
+	if c.address == "" {
+		return nil, fmt.Errorf("clamd address is empty")
+	}
+	return c, nil
+}
+
+// Address returns the address the client connects to
+func (c *Client) Address() string {
+	return c.network + "://" + c.address
+}
+
+// dial connects to clamd with the client's timeout applied to the whole exchange
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	return conn, nil
+}
+
+// readReply reads a null- or newline-terminated reply
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(io.LimitReader(conn, maxReplyLen)).ReadString(0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+// Ping checks that clamd is up and responding
+func (c *Client) Ping(ctx context.Context) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("%w: unexpected reply %q", ErrUnavailable, reply)
+	}
+	return nil
+}
+
+// Scan streams r to clamd with the INSTREAM command and returns its verdict
+func (c *Client) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+	// A request that goes away stops its scan rather than waiting out the timeout
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// clamd may close the connection early (e.g. on a size limit) and still send a
+	// reply, so a failed write falls through to reading it
+	writeErr := writeStream(conn, r)
+	reply, err := readReply(conn)
+	if err != nil || reply == "" {
+		if writeErr != nil {
+			return Result{}, fmt.Errorf("%w: %v", ErrUnavailable, writeErr)
+		}
+		return Result{}, fmt.Errorf("%w: no reply from clamd", ErrUnavailable)
+	}
+
+	return parseReply(reply)
+}
+
+// writeStream sends the INSTREAM command, the content in length-prefixed chunks and the terminator
+func writeStream(w io.Writer, r io.Reader) error {
+	if _, err := w.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// parseReply interprets a reply such as "stream: OK" or "stream: Eicar-Signature FOUND"
+func parseReply(reply string) (Result, error) {
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return Result{}, fmt.Errorf("%w: %s", ErrScanFailed, verdict)
+	}
+}
@@ -0,0 +1,117 @@
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the Cloudflare v4 API
+const DefaultAPIURL = "https://api.cloudflare.com/client/v4"
+
+// MaxURLsPerRequest is the most URLs Cloudflare accepts in one purge request
+const MaxURLsPerRequest = 30
+
+// maxResponseLen bounds how much of an API response is read
+const maxResponseLen = 64 * 1024
+
+// RateLimitError is returned when Cloudflare rejects a request with 429
+type RateLimitError struct {
+	// RetryAfter is how long Cloudflare asked to wait, or zero if it didn't say
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("cloudflare rate limit exceeded, retry after %s", e.RetryAfter)
+	}
+	return "cloudflare rate limit exceeded"
+}
+
+// Client purges cached URLs from a Cloudflare zone
+type Client struct {
+	apiURL string
+	zoneID string
+	token  string
+	http   *http.Client
+}
+
+// New creates a client for a zone using an API token with the Cache Purge permission.
+// apiURL is DefaultAPIURL outside of tests.
+func New(apiURL, zoneID, token string, timeout time.Duration) *Client {
+	return &Client{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		zoneID: zoneID,
+		token:  token,
+		http:   &http.Client{Timeout: timeout},
+	}
+}
+
+// apiResponse is the envelope of every Cloudflare API response
+type apiResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// PurgeURLs purges up to MaxURLsPerRequest URLs in a single request
+func (c *Client) PurgeURLs(ctx context.Context, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	if len(urls) > MaxURLsPerRequest {
+		return fmt.Errorf("cannot purge more than %d URLs per request", MaxURLsPerRequest)
+	}
+
+	body, err := json.Marshal(map[string][]string{"files": urls})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.apiURL+"/zones/"+c.zoneID+"/purge_cache", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseLen))
+		var retryAfter time.Duration
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+
+	var result apiResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseLen)).Decode(&result); err != nil {
+		return fmt.Errorf("cloudflare returned %s", resp.Status)
+	}
+	if !result.Success {
+		msgs := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			msgs[i] = fmt.Sprintf("%s (%d)", e.Message, e.Code)
+		}
+		if len(msgs) == 0 {
+			msgs = append(msgs, resp.Status)
+		}
+		return errors.New("cloudflare purge failed: " + strings.Join(msgs, "; "))
+	}
+	return nil
+}
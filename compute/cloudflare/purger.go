@@ -0,0 +1,165 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitWait is how long to back off after a 429 without a Retry-After header
+	rateLimitWait = 10 * time.Second
+	// maxRateLimitWaits bounds how often a single batch waits out the rate limit
+	maxRateLimitWaits = 5
+	// maxAttempts is how often the background purger tries a batch that fails for other reasons
+	maxAttempts = 3
+	// retryDelay is the wait before the first retry of a failed batch; it doubles each time
+	retryDelay = 5 * time.Second
+)
+
+// Purger collects URLs to purge and sends them in batches in the background,
+// so a burst of changes becomes a few API requests instead of one per file
+type Purger struct {
+	client *Client
+	window time.Duration
+	wake   chan struct{}
+	ctx    context.Context
+	stop   context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	pending []string
+	queued  map[string]bool
+}
+
+// NewPurger creates a purger that waits window after the first queued URL for
+// more to arrive before purging them together
+func NewPurger(client *Client, window time.Duration) *Purger {
+	ctx, stop := context.WithCancel(context.Background())
+	p := &Purger{
+		client: client,
+		window: window,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		stop:   stop,
+		queued: make(map[string]bool),
+	}
+
+	p.wg.Add(1)
+	go p.loop()
+	return p
+}
+
+// Queue schedules URLs to be purged; URLs already waiting are not queued twice
+func (p *Purger) Queue(urls ...string) {
+	p.mu.Lock()
+	for _, u := range urls {
+		if !p.queued[u] {
+			p.queued[u] = true
+			p.pending = append(p.pending, u)
+		}
+	}
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Purge purges URLs now, in as many requests as needed, waiting out rate limits.
+// It returns how many URLs were purged before an error stopped it.
+func (p *Purger) Purge(ctx context.Context, urls []string) (int, error) {
+	purged := 0
+	for start := 0; start < len(urls); start += MaxURLsPerRequest {
+		batch := urls[start:min(start+MaxURLsPerRequest, len(urls))]
+		if err := p.purgeBatch(ctx, batch); err != nil {
+			return purged, err
+		}
+		purged += len(batch)
+	}
+	return purged, nil
+}
+
+// purgeBatch sends one request, retrying while Cloudflare reports a rate limit
+func (p *Purger) purgeBatch(ctx context.Context, batch []string) error {
+	for waits := 0; ; waits++ {
+		err := p.client.PurgeURLs(ctx, batch)
+		var rateLimit *RateLimitError
+		if !errors.As(err, &rateLimit) || waits == maxRateLimitWaits {
+			return err
+		}
+
+		wait := rateLimit.RetryAfter
+		if wait <= 0 {
+			wait = rateLimitWait
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Shutdown stops the purger; URLs still waiting are dropped
+func (p *Purger) Shutdown() {
+	p.stop()
+	p.wg.Wait()
+}
+
+// loop purges queued URLs once the batching window has passed
+func (p *Purger) loop() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.wake:
+		}
+		if sleep(p.ctx, p.window) != nil {
+			return
+		}
+
+		p.mu.Lock()
+		urls := p.pending
+		p.pending = nil
+		p.queued = make(map[string]bool)
+		p.mu.Unlock()
+
+		p.purgeWithRetry(urls)
+	}
+}
+
+// purgeWithRetry purges URLs in the background, retrying failed batches a few times
+func (p *Purger) purgeWithRetry(urls []string) {
+	delay := retryDelay
+	for attempt := 1; len(urls) > 0; attempt++ {
+		purged, err := p.Purge(p.ctx, urls)
+		if err == nil || p.ctx.Err() != nil {
+			return
+		}
+		urls = urls[purged:]
+		if attempt == maxAttempts {
+			slog.Error("Cloudflare purge failed", "urls", len(urls), "error", err)
+			return
+		}
+		if sleep(p.ctx, delay) != nil {
+			return
+		}
+		delay *= 2
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
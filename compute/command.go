@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"hextech-panel/config"
+)
+
+// runCommand runs a command given on the command line instead of starting the server,
+// returning the exit status
+func runCommand(configFile string, args []string) int {
+	if len(args) != 2 || args[0] != "config" || args[1] != "print" {
+		flag.Usage()
+		return 2
+	}
+
+	// Print what was loaded even when it is invalid, so the problems can be traced to a source
+	c, err := config.Load(configFile)
+	if c == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if perr := c.Print(os.Stdout); perr != nil {
+		fmt.Fprintln(os.Stderr, perr)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\nInvalid configuration:")
+		printErrors(err)
+		return 1
+	}
+	return 0
+}
+
+// printErrors writes each of the errors joined in err on its own line
+func printErrors(err error) {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		fmt.Fprintln(os.Stderr, "  "+err.Error())
+		return
+	}
+	for _, e := range joined.Unwrap() {
+		fmt.Fprintln(os.Stderr, "  "+e.Error())
+	}
+}
@@ -1,71 +1,412 @@
 package config
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
-// Config holds all application configuration loaded from environment variables
-var (
+// Config holds all application configuration. Every field is set from its default, then
+// the configuration file, then the environment variable named by its env tag; in the
+// file the key is the variable name in lowercase, e.g. max_upload_size.
+type Config struct {
+	// Port is the port the panel listens on
+	Port string `env:"PORT" default:"8080"`
+
+	// DBPath is the SQLite database file
+	DBPath string `env:"DB_PATH" default:"./hextech.db"`
+
+	// StaticDir serves the built frontend when set
+	StaticDir string `env:"STATIC_DIR"`
+
+	// DevMode disables authentication and opens admin endpoints to everyone. Development only.
+	DevMode bool `env:"DEV_MODE" default:"false"`
+
+	// BypassCFAuth skips the Cloudflare Access check while keeping the rest of the
+	// protected route middleware. Development only.
+	BypassCFAuth bool `env:"BYPASS_CF_AUTH" default:"false"`
+
 	// CDNPath is the base directory where files are stored
-	// Default: /srv/cdn
-	CDNPath string
+	CDNPath string `env:"CDN_PATH" default:"/srv/cdn"`
 
 	// PublicHostname is the public domain used for generating share URLs
-	// Default: localhost
-	PublicHostname string
+	PublicHostname string `env:"PUBLIC_HOSTNAME" default:"localhost"`
+
+	// MaxUploadSize is the maximum file upload size
+	MaxUploadSize ByteSize `env:"MAX_UPLOAD_SIZE" default:"100MB"`
+
+	// MaxUploadFiles caps the number of files in a single upload request
+	MaxUploadFiles int `env:"MAX_UPLOAD_FILES" default:"100"`
 
-	// MaxUploadSize is the maximum file upload size in bytes
-	// Default: 104857600 (100MB)
-	MaxUploadSize int64
+	// MaxUploadRequestSize caps the total size of a single upload request
+	MaxUploadRequestSize ByteSize `env:"MAX_UPLOAD_REQUEST_SIZE" default:"1GB"`
 
-	// AllowedOrigins is a list of allowed CORS origins
-	// Default: * (all origins - suitable for same-origin deployment)
-	AllowedOrigins []string
+	// AllowedOrigins is a list of allowed CORS origins; * allows all origins,
+	// which suits a same-origin deployment
+	AllowedOrigins []string `env:"ALLOWED_ORIGINS" default:"*"`
 
 	// BlockedExtensions is the default list of blocked file extensions
-	BlockedExtensions string
-)
+	BlockedExtensions string `env:"BLOCKED_EXTENSIONS" default:"php,phtml,phar,cgi,pl,py,sh,exe,dll,so,bin,bat,cmd,ps1,asp,aspx,jsp,jspx,cfm,htaccess"`
+
+	// CacheDir holds generated artifacts such as thumbnails and transformed images
+	// Must be outside CDNPath so cached files are never publicly served
+	CacheDir string `env:"CACHE_DIR" default:"/data/cache"`
+
+	// ThumbnailConcurrency limits how many thumbnails and image transforms are generated at once
+	ThumbnailConcurrency int `env:"THUMBNAIL_CONCURRENCY" default:"2"`
+
+	// ExtractMaxEntries caps the number of entries in an uploaded archive
+	ExtractMaxEntries int `env:"EXTRACT_MAX_ENTRIES" default:"1000"`
+
+	// ExtractMaxSize caps the total uncompressed size of an uploaded archive
+	ExtractMaxSize ByteSize `env:"EXTRACT_MAX_SIZE" default:"1GB"`
+
+	// ExtractMaxRatio caps the compression ratio of an uploaded archive (zip bomb protection)
+	ExtractMaxRatio int64 `env:"EXTRACT_MAX_RATIO" default:"100"`
+
+	// ExportMaxFiles caps the number of files in a single ZIP export
+	ExportMaxFiles int `env:"EXPORT_MAX_FILES" default:"10000"`
+
+	// ExportMaxSize caps the total uncompressed size of a single ZIP export
+	ExportMaxSize ByteSize `env:"EXPORT_MAX_SIZE" default:"2GB"`
+
+	// ExportConcurrency limits how many export jobs run at once
+	ExportConcurrency int `env:"EXPORT_CONCURRENCY" default:"2"`
+
+	// ExportTTLMinutes is how long finished export archives are kept for download
+	ExportTTLMinutes int64 `env:"EXPORT_TTL_MINUTES" default:"60"`
+
+	// SanitizeSVG strips scripts, event handlers and external references from uploaded SVGs
+	// instead of rejecting them
+	SanitizeSVG bool `env:"SANITIZE_SVG" default:"false"`
+
+	// ClamdAddress enables virus scanning of uploads through clamd, e.g. tcp://127.0.0.1:3310,
+	// unix:///run/clamav/clamd.ctl or /run/clamav/clamd.ctl. Scanning is disabled when empty.
+	ClamdAddress string `env:"CLAMD_ADDRESS"`
+
+	// ClamdTimeoutSeconds bounds a single scan, including connecting to clamd
+	ClamdTimeoutSeconds int64 `env:"CLAMD_TIMEOUT_SECONDS" default:"60"`
+
+	// ScanFailMode decides what happens to uploads when clamd is unreachable or cannot scan:
+	// "closed" rejects them, "open" accepts them unscanned
+	ScanFailMode string `env:"SCAN_FAIL_MODE" default:"closed"`
+
+	// QuarantineDir holds infected uploads; must be outside CDNPath
+	QuarantineDir string `env:"QUARANTINE_DIR" default:"/data/quarantine"`
+
+	// WebhookMaxAttempts is how many times a webhook delivery is tried before it is marked failed
+	WebhookMaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" default:"8"`
+
+	// WebhookTimeoutSeconds bounds a single webhook delivery attempt
+	WebhookTimeoutSeconds int64 `env:"WEBHOOK_TIMEOUT_SECONDS" default:"10"`
+
+	// WebhookAllowedNetworks lists CIDR ranges or addresses that webhooks may reach even
+	// though they are loopback, private or link-local; such destinations are refused otherwise
+	WebhookAllowedNetworks []string `env:"WEBHOOK_ALLOWED_NETWORKS"`
+
+	// EventRetention is how many recent file events are kept for event streams to resume
+	// from; clients further behind are told to reload
+	EventRetention int `env:"EVENT_RETENTION" default:"10000"`
 
-// Init loads configuration from environment variables
-// Should be called once at application startup
-func Init() {
-	CDNPath = getEnvOrDefault("CDN_PATH", "/srv/cdn")
-	PublicHostname = getEnvOrDefault("PUBLIC_HOSTNAME", "localhost")
-	MaxUploadSize = getEnvOrDefaultInt64("MAX_UPLOAD_SIZE", 104857600) // 100MB
-	BlockedExtensions = getEnvOrDefault("BLOCKED_EXTENSIONS", "php,phtml,phar,cgi,pl,py,sh,exe,dll,so,bin,bat,cmd,ps1,asp,aspx,jsp,jspx,cfm,htaccess")
-
-	// Parse CORS origins
-	originsStr := getEnvOrDefault("ALLOWED_ORIGINS", "*")
-	if originsStr == "*" {
-		AllowedOrigins = []string{"*"}
-	} else {
-		origins := strings.Split(originsStr, ",")
-		AllowedOrigins = make([]string, 0, len(origins))
-		for _, o := range origins {
-			o = strings.TrimSpace(o)
-			if o != "" {
-				AllowedOrigins = append(AllowedOrigins, o)
+	// CloudflareZoneID and CloudflareAPIToken enable purging changed files from Cloudflare's cache.
+	// The token needs the Zone > Cache Purge permission. Purging is disabled when both are empty.
+	CloudflareZoneID   string `env:"CLOUDFLARE_ZONE_ID"`
+	CloudflareAPIToken string `env:"CLOUDFLARE_API_TOKEN" secret:"true"`
+
+	// CloudflareAPIURL is the Cloudflare API base URL, overridable for testing
+	CloudflareAPIURL string `env:"CLOUDFLARE_API_URL" default:"https://api.cloudflare.com/client/v4"`
+
+	// MetricsAddress is the listen address of the Prometheus /metrics endpoint, kept
+	// off the main listener so it isn't reachable through the tunnel, e.g. 127.0.0.1:9100.
+	// The endpoint is disabled when empty.
+	MetricsAddress string `env:"METRICS_ADDR"`
+
+	// LogLevel is the minimum level logged: debug, info, warn or error
+	LogLevel string `env:"LOG_LEVEL" default:"info"`
+
+	// LogFormat selects structured log output: json or text
+	LogFormat string `env:"LOG_FORMAT" default:"json"`
+
+	// AdminEmails lists the users allowed to use admin endpoints such as diagnostics.
+	// Without any, admin endpoints are only open in DEV_MODE.
+	AdminEmails []string `env:"ADMIN_EMAILS"`
+
+	// MinFreeDiskBytes is the free space below which the storage volume reports not ready
+	MinFreeDiskBytes ByteSize `env:"MIN_FREE_DISK_BYTES" default:"1GB"`
+
+	// ReadHeaderTimeoutSeconds bounds how long a client may take to send request headers
+	ReadHeaderTimeoutSeconds int64 `env:"HTTP_READ_HEADER_TIMEOUT_SECONDS" default:"10"`
+
+	// ReadTimeoutSeconds and WriteTimeoutSeconds bound reading a request body and writing
+	// the response on normal routes
+	ReadTimeoutSeconds  int64 `env:"HTTP_READ_TIMEOUT_SECONDS" default:"30"`
+	WriteTimeoutSeconds int64 `env:"HTTP_WRITE_TIMEOUT_SECONDS" default:"60"`
+
+	// UploadTimeoutSeconds replaces both timeouts on routes that move large bodies:
+	// uploads, replacements, archive extraction and archive downloads
+	UploadTimeoutSeconds int64 `env:"UPLOAD_TIMEOUT_SECONDS" default:"3600"`
+
+	// IdleTimeoutSeconds is how long an idle keep-alive connection is kept open
+	IdleTimeoutSeconds int64 `env:"HTTP_IDLE_TIMEOUT_SECONDS" default:"120"`
+
+	// ShutdownTimeoutSeconds is how long in-flight requests may take to finish after SIGTERM
+	ShutdownTimeoutSeconds int64 `env:"SHUTDOWN_TIMEOUT_SECONDS" default:"25"`
+
+	// TLSCertFile and TLSKeyFile enable HTTPS and HTTP/2 on the panel listener. The files
+	// are reloaded when they change, so renewed certificates need no restart. Without them
+	// the panel serves plain HTTP, for running behind Cloudflare Tunnel or a reverse proxy.
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+
+	// TrustedProxy declares that with TLS enabled the panel is still only reachable through
+	// Cloudflare Access or another proxy that sets the identity header. Without it, TLS
+	// requires TLSClientCAFile, since clients could otherwise send the header themselves.
+	TrustedProxy bool `env:"TRUSTED_PROXY" default:"false"`
+
+	// TLSClientCAFile enables client certificate authentication: certificates signed by these
+	// CAs identify the user in place of Cloudflare Access. Reloaded when it changes.
+	TLSClientCAFile string `env:"TLS_CLIENT_CA_FILE"`
+
+	// TLSClientAuth is "require" to reject connections without a valid client certificate,
+	// or "optional" to verify one only when presented
+	TLSClientAuth string `env:"TLS_CLIENT_AUTH" default:"require"`
+
+	// TLSClientIdentities maps client certificate email addresses or common names to users,
+	// e.g. "build-bot=ci@example.com". Unmapped certificates are identified by their first
+	// email address, then their common name.
+	TLSClientIdentities map[string]string `env:"TLS_CLIENT_IDENTITIES"`
+
+	// HTTPRedirectAddress is a plain HTTP listen address that redirects to HTTPS, e.g. :80
+	HTTPRedirectAddress string `env:"HTTP_REDIRECT_ADDR"`
+
+	// file is the configuration file that was loaded, if any
+	file string
+	// sources records where each setting came from, keyed by environment variable
+	sources map[string]string
+}
+
+// Current is the configuration the application runs with, set by Init
+var Current = &Config{}
+
+// Init loads the configuration from the file at path, if any, and the environment,
+// and makes it Current. Should be called once at application startup.
+func Init(path string) error {
+	c, err := Load(path)
+	if err != nil {
+		return err
+	}
+	Current = c
+	return nil
+}
+
+// Load builds a configuration from defaults, the YAML or TOML file at path when path
+// is set, and environment variables, each overriding the last, then validates it.
+// Every problem found is reported in the returned error. A configuration is returned
+// alongside validation errors so it can still be inspected.
+func Load(path string) (*Config, error) {
+	c := &Config{file: path, sources: make(map[string]string)}
+
+	var errs []error
+	for _, f := range c.fields() {
+		if f.def != "" {
+			if err := f.set(f.def); err != nil {
+				panic(fmt.Sprintf("config: invalid default for %s: %v", f.env, err))
 			}
 		}
+		c.sources[f.env] = SourceDefault
+	}
+
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, c.applyFile(values)...)
+	}
+	errs = append(errs, c.applyEnv()...)
+
+	c.normalize()
+	errs = append(errs, c.validate()...)
+	return c, errors.Join(errs...)
+}
+
+// normalize puts settings into the form the rest of the application compares against
+func (c *Config) normalize() {
+	// Cloudflare Access and client certificate emails are compared lowercased
+	for i, e := range c.AdminEmails {
+		c.AdminEmails[i] = strings.ToLower(e)
+	}
+	identities := make(map[string]string, len(c.TLSClientIdentities))
+	for name, user := range c.TLSClientIdentities {
+		identities[strings.ToLower(name)] = strings.ToLower(user)
+	}
+	c.TLSClientIdentities = identities
+}
+
+// validate checks settings whose values are well-formed but not usable
+func (c *Config) validate() []error {
+	var errs []error
+	invalid := func(env, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", env, fmt.Sprintf(format, args...)))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		invalid("PORT", "%q is not a port number", c.Port)
+	}
+	if c.CDNPath == "" {
+		invalid("CDN_PATH", "must be set")
+	}
+	if c.PublicHostname == "" {
+		invalid("PUBLIC_HOSTNAME", "must be set")
+	}
+	if len(c.AllowedOrigins) == 0 {
+		invalid("ALLOWED_ORIGINS", "must list at least one origin, or * for all")
+	}
+	if c.CDNPath != "" {
+		if isInside(c.CDNPath, c.CacheDir) {
+			invalid("CACHE_DIR", "must be outside CDN_PATH so cached files are never publicly served")
+		}
+		if isInside(c.CDNPath, c.QuarantineDir) {
+			invalid("QUARANTINE_DIR", "must be outside CDN_PATH so infected files are never publicly served")
+		}
+	}
+
+	positive := map[string]int64{
+		"MAX_UPLOAD_SIZE":         int64(c.MaxUploadSize),
+		"MAX_UPLOAD_FILES":        int64(c.MaxUploadFiles),
+		"MAX_UPLOAD_REQUEST_SIZE": int64(c.MaxUploadRequestSize),
+		"THUMBNAIL_CONCURRENCY":   int64(c.ThumbnailConcurrency),
+		"EXTRACT_MAX_ENTRIES":     int64(c.ExtractMaxEntries),
+		"EXTRACT_MAX_SIZE":        int64(c.ExtractMaxSize),
+		"EXTRACT_MAX_RATIO":       c.ExtractMaxRatio,
+		"EXPORT_MAX_FILES":        int64(c.ExportMaxFiles),
+		"EXPORT_MAX_SIZE":         int64(c.ExportMaxSize),
+		"EXPORT_CONCURRENCY":      int64(c.ExportConcurrency),
+		"EXPORT_TTL_MINUTES":      c.ExportTTLMinutes,
+		"CLAMD_TIMEOUT_SECONDS":   c.ClamdTimeoutSeconds,
+		"WEBHOOK_MAX_ATTEMPTS":    int64(c.WebhookMaxAttempts),
+		"WEBHOOK_TIMEOUT_SECONDS": c.WebhookTimeoutSeconds,
+		"EVENT_RETENTION":         int64(c.EventRetention),
+		"UPLOAD_TIMEOUT_SECONDS":  c.UploadTimeoutSeconds,
+		// A zero drain timeout would cut off every request on shutdown
+		"SHUTDOWN_TIMEOUT_SECONDS": c.ShutdownTimeoutSeconds,
+	}
+	// Zero turns these timeouts off
+	nonNegative := map[string]int64{
+		"MIN_FREE_DISK_BYTES":              int64(c.MinFreeDiskBytes),
+		"HTTP_READ_HEADER_TIMEOUT_SECONDS": c.ReadHeaderTimeoutSeconds,
+		"HTTP_READ_TIMEOUT_SECONDS":        c.ReadTimeoutSeconds,
+		"HTTP_WRITE_TIMEOUT_SECONDS":       c.WriteTimeoutSeconds,
+		"HTTP_IDLE_TIMEOUT_SECONDS":        c.IdleTimeoutSeconds,
+	}
+	for env, v := range positive {
+		if v <= 0 {
+			invalid(env, "must be greater than zero")
+		}
+	}
+	for env, v := range nonNegative {
+		if v < 0 {
+			invalid(env, "must not be negative")
+		}
+	}
+
+	if c.ScanFailMode != "open" && c.ScanFailMode != "closed" {
+		invalid("SCAN_FAIL_MODE", "%q is not open or closed", c.ScanFailMode)
+	}
+	if c.ClamdAddress != "" && !strings.HasPrefix(c.ClamdAddress, "tcp://") && !strings.HasPrefix(c.ClamdAddress, "unix://") && !strings.HasPrefix(c.ClamdAddress, "/") {
+		invalid("CLAMD_ADDRESS", "%q must start with tcp://, unix:// or /", c.ClamdAddress)
+	}
+	for _, network := range c.WebhookAllowedNetworks {
+		if !validNetwork(network) {
+			invalid("WEBHOOK_ALLOWED_NETWORKS", "%q is not an IP address or CIDR range", network)
+		}
+	}
+	if (c.CloudflareZoneID == "") != (c.CloudflareAPIToken == "") {
+		invalid("CLOUDFLARE_ZONE_ID", "CLOUDFLARE_ZONE_ID and CLOUDFLARE_API_TOKEN must be set together")
+	}
+	if u, err := url.Parse(c.CloudflareAPIURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		invalid("CLOUDFLARE_API_URL", "%q is not an http(s) URL", c.CloudflareAPIURL)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		invalid("LOG_LEVEL", "%q is not debug, info, warn or error", c.LogLevel)
+	}
+	if f := strings.ToLower(c.LogFormat); f != "json" && f != "text" {
+		invalid("LOG_FORMAT", "%q is not json or text", c.LogFormat)
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		invalid("TLS_CERT_FILE", "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLSCertFile == "" {
+		if c.TLSClientCAFile != "" {
+			invalid("TLS_CLIENT_CA_FILE", "requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		if c.HTTPRedirectAddress != "" {
+			invalid("HTTP_REDIRECT_ADDR", "requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+	}
+	if c.TLSCertFile != "" && c.TLSClientCAFile == "" && !c.TrustedProxy && !c.DevMode {
+		invalid("TLS_CLIENT_CA_FILE", "must be set with TLS_CERT_FILE so clients cannot claim any identity; set TRUSTED_PROXY=true if only Cloudflare Access or another proxy that sets the identity header can reach the panel")
 	}
+	if c.TLSClientAuth != "require" && c.TLSClientAuth != "optional" {
+		invalid("TLS_CLIENT_AUTH", "%q is not require or optional", c.TLSClientAuth)
+	}
+
+	return errs
 }
 
-// getEnvOrDefault returns env variable value or default if not set
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// isInside reports whether dir is base itself or located beneath it
+func isInside(base, dir string) bool {
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return false
 	}
-	return defaultValue
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(base, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// File returns the configuration file that was loaded, or "" when there was none
+func (c *Config) File() string {
+	return c.file
 }
 
-// getEnvOrDefaultInt64 returns env variable as int64 or default if not set/invalid
-func getEnvOrDefaultInt64(key string, defaultValue int64) int64 {
-	if value := os.Getenv(key); value != "" {
-		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
-			return parsed
+// Redacted returns the configuration keyed by environment variable, with secrets hidden
+func (c *Config) Redacted() map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, f := range c.fields() {
+		if f.secret {
+			values[f.env] = secretValue(f.value.String())
+		} else {
+			values[f.env] = f.value.Interface()
 		}
 	}
-	return defaultValue
+	return values
+}
+
+// secretValue marks a configured secret for display without revealing it
+func secretValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+// validNetwork reports whether s is a CIDR range or a single IP address
+func validNetwork(s string) bool {
+	if _, err := netip.ParsePrefix(s); err == nil {
+		return true
+	}
+	_, err := netip.ParseAddr(s)
+	return err == nil
 }
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Where a setting's value came from
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+var byteSizeType = reflect.TypeOf(ByteSize(0))
+
+// field is one setting of a Config
+type field struct {
+	env    string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields lists the settings of c in declaration order
+func (c *Config) fields() []field {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		env := sf.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		fields = append(fields, field{
+			env:    env,
+			def:    sf.Tag.Get("default"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return fields
+}
+
+// key is the name of the setting in a configuration file
+func (f field) key() string {
+	return strings.ToLower(f.env)
+}
+
+// set parses s as written in an environment variable: sizes such as 100MB, and
+// comma-separated lists and name=value pairs
+func (f field) set(s string) error {
+	s = strings.TrimSpace(s)
+	v := f.value
+	if v.Type() == byteSizeType {
+		size, err := ParseByteSize(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(size))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", s)
+		}
+		v.SetInt(n)
+	case reflect.Slice:
+		return f.setList(strings.Split(s, ","))
+	case reflect.Map:
+		pairs := make(map[string]string)
+		for _, pair := range strings.Split(s, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(pair, "=")
+			name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+			if !ok || name == "" || value == "" {
+				return fmt.Errorf("%q is not a name=value pair", pair)
+			}
+			pairs[name] = value
+		}
+		return f.setMap(pairs)
+	default:
+		panic("config: unsupported type for " + f.env)
+	}
+	return nil
+}
+
+// setList sets a list setting, dropping empty items
+func (f field) setList(items []string) error {
+	if f.value.Kind() != reflect.Slice {
+		return fmt.Errorf("expected a single value, not a list")
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	f.value.Set(reflect.ValueOf(list))
+	return nil
+}
+
+// setMap sets a name=value setting
+func (f field) setMap(pairs map[string]string) error {
+	if f.value.Kind() != reflect.Map {
+		return fmt.Errorf("expected a single value, not a table")
+	}
+	f.value.Set(reflect.ValueOf(pairs))
+	return nil
+}
+
+// setFileValue sets the setting from a decoded YAML or TOML value. Strings are parsed
+// like environment variables, so a file may say either 1GB or 1073741824.
+func (f field) setFileValue(raw interface{}) error {
+	switch raw := raw.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		items := make([]string, 0, len(raw))
+		for _, item := range raw {
+			s, err := scalar(item)
+			if err != nil {
+				return err
+			}
+			items = append(items, s)
+		}
+		return f.setList(items)
+	case map[string]interface{}:
+		pairs := make(map[string]string, len(raw))
+		for name, value := range raw {
+			s, err := scalar(value)
+			if err != nil {
+				return err
+			}
+			pairs[name] = s
+		}
+		return f.setMap(pairs)
+	default:
+		s, err := scalar(raw)
+		if err != nil {
+			return err
+		}
+		return f.set(s)
+	}
+}
+
+// scalar formats a single decoded value as the text form the setting parsers expect
+func scalar(raw interface{}) (string, error) {
+	switch raw := raw.(type) {
+	case string:
+		return raw, nil
+	case bool, int, int64, uint64:
+		return fmt.Sprint(raw), nil
+	case float64:
+		return strconv.FormatFloat(raw, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", raw)
+	}
+}
+
+// readFile decodes a YAML or TOML configuration file, chosen by its extension
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// applyFile sets the settings present in a configuration file. Unknown keys are errors,
+// so a misspelled setting is not silently ignored.
+func (c *Config) applyFile(values map[string]interface{}) []error {
+	fields := make(map[string]field)
+	for _, f := range c.fields() {
+		fields[f.key()] = f
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, k := range keys {
+		f, ok := fields[k]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", c.file, k))
+			continue
+		}
+		if err := f.setFileValue(values[k]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", c.file, k, err))
+			continue
+		}
+		c.sources[f.env] = SourceFile
+	}
+	return errs
+}
+
+// applyEnv sets the settings whose environment variables are set and not empty
+func (c *Config) applyEnv() []error {
+	var errs []error
+	for _, f := range c.fields() {
+		value := os.Getenv(f.env)
+		if value == "" {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			continue
+		}
+		c.sources[f.env] = SourceEnv
+	}
+	return errs
+}
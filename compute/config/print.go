@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Print writes every setting with its effective value and where it came from, as
+// environment variable, value and source columns. Secrets are hidden.
+func (c *Config) Print(w io.Writer) error {
+	if c.file != "" {
+		fmt.Fprintf(w, "# config file: %s\n", c.file)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, f := range c.fields() {
+		value := f.display()
+		if f.secret {
+			value = secretValue(value)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.env, value, c.sources[f.env])
+	}
+	return tw.Flush()
+}
+
+// display formats the value the way it would be written in an environment variable
+func (f field) display() string {
+	v := f.value
+	switch {
+	case v.Type() == byteSizeType:
+		return ByteSize(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	case v.Kind() == reflect.Map:
+		m := v.Interface().(map[string]string)
+		pairs := make([]string, 0, len(m))
+		for name, value := range m {
+			pairs = append(pairs, name+"="+value)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size in bytes, written either as a plain number of bytes or with a unit
+// such as 512KB, 100MB or 1.5GB. Units are binary, as everywhere in the panel: 1KB is
+// 1024 bytes, and KiB, MiB, GiB and TiB are accepted as synonyms.
+type ByteSize int64
+
+// byteUnits are the size units, largest first
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+}
+
+// ParseByteSize parses a size such as 1073741824, 1GB, 1 GiB or 1.5g
+func ParseByteSize(s string) (ByteSize, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	text = strings.TrimSuffix(strings.Replace(text, "IB", "B", 1), "B")
+
+	multiplier := int64(1)
+	if text != "" {
+		for _, u := range byteUnits {
+			if strings.HasSuffix(text, u.suffix[:1]) {
+				multiplier = u.size
+				text = text[:len(text)-1]
+				break
+			}
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("%q is not a size, e.g. 104857600, 100MB or 1.5GB", s)
+	}
+	bytes := n * float64(multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("%q is too large", s)
+	}
+	return ByteSize(bytes), nil
+}
+
+// String formats the size with the largest unit that represents it exactly
+func (b ByteSize) String() string {
+	for _, u := range byteUnits {
+		if b != 0 && int64(b)%u.size == 0 {
+			return strconv.FormatInt(int64(b)/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(b), 10)
+}
@@ -1,9 +1,11 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
-	"log"
+	"log/slog"
+	"os"
 	"sync"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -14,6 +16,7 @@ var schemaSQL string
 
 var (
 	database *sql.DB
+	path     string
 	once     sync.Once
 )
 
@@ -27,6 +30,7 @@ func Init(dbPath string) error {
 			initErr = err
 			return
 		}
+		path = dbPath
 
 		// Run schema
 		if _, err := database.Exec(schemaSQL); err != nil {
@@ -34,7 +38,13 @@ func Init(dbPath string) error {
 			return
 		}
 
-		log.Println("Database initialized successfully")
+		// Upgrade databases created by older versions
+		if err := migrate(); err != nil {
+			initErr = err
+			return
+		}
+
+		slog.Info("Database initialized successfully")
 	})
 	return initErr
 }
@@ -44,6 +54,28 @@ func Get() *sql.DB {
 	return database
 }
 
+// Ping verifies that the database answers queries
+func Ping(ctx context.Context) error {
+	var one int
+	return database.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+// Size returns the path of the database file and the bytes used by it and its write-ahead log
+func Size() (string, int64, error) {
+	var total int64
+	for _, suffix := range []string{"", "-wal"} {
+		info, err := os.Stat(path + suffix)
+		if err != nil {
+			if suffix != "" && os.IsNotExist(err) {
+				continue
+			}
+			return path, 0, err
+		}
+		total += info.Size()
+	}
+	return path, total, nil
+}
+
 // Close closes the database connection
 func Close() error {
 	if database != nil {
@@ -52,18 +84,21 @@ func Close() error {
 	return nil
 }
 
-// LogActivity records an activity in the log
-func LogActivity(action, filePath, sourceIP string) error {
-	_, err := database.Exec(
+// LogActivity records an activity in the log and returns its ID
+func LogActivity(action, filePath, sourceIP string) (int64, error) {
+	res, err := exec(
 		"INSERT INTO activity_log (action, file_path, source_ip) VALUES (?, ?, ?)",
 		action, filePath, sourceIP,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
 }
 
 // GetLogs retrieves activity logs with pagination
 func GetLogs(limit, offset int) ([]ActivityLog, error) {
-	rows, err := database.Query(
+	rows, err := query(
 		"SELECT id, timestamp, action, file_path, source_ip FROM activity_log ORDER BY timestamp DESC LIMIT ? OFFSET ?",
 		limit, offset,
 	)
@@ -97,19 +132,19 @@ type ActivityLog struct {
 // GetSetting retrieves a setting value
 func GetSetting(key string) (string, error) {
 	var value string
-	err := database.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	err := queryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
 	return value, err
 }
 
 // SetSetting updates a setting value
 func SetSetting(key, value string) error {
-	_, err := database.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value)
+	_, err := exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value)
 	return err
 }
 
 // GetAllSettings retrieves all settings
 func GetAllSettings() (map[string]string, error) {
-	rows, err := database.Query("SELECT key, value FROM settings")
+	rows, err := query("SELECT key, value FROM settings")
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +163,7 @@ func GetAllSettings() (map[string]string, error) {
 
 // SaveFileHash stores a file's SHA256 hash
 func SaveFileHash(path, hash string) error {
-	_, err := database.Exec(
+	_, err := exec(
 		"INSERT OR REPLACE INTO file_metadata (path, sha256, computed_at) VALUES (?, ?, CURRENT_TIMESTAMP)",
 		path, hash,
 	)
@@ -138,12 +173,16 @@ func SaveFileHash(path, hash string) error {
 // GetFileHash retrieves a cached file hash
 func GetFileHash(path string) (string, error) {
 	var hash string
-	err := database.QueryRow("SELECT sha256 FROM file_metadata WHERE path = ?", path).Scan(&hash)
+	err := queryRow("SELECT sha256 FROM file_metadata WHERE path = ?", path).Scan(&hash)
 	return hash, err
 }
 
-// DeleteFileHash removes a cached hash entry
+// DeleteFileHash removes the cached hash of a path, and of everything beneath it when
+// the path is a directory
 func DeleteFileHash(path string) error {
-	_, err := database.Exec("DELETE FROM file_metadata WHERE path = ?", path)
+	_, err := exec(
+		"DELETE FROM file_metadata WHERE path = ? OR substr(path, 1, ?) = ?",
+		path, len(path)+1, path+"/",
+	)
 	return err
 }
@@ -0,0 +1,66 @@
+package db
+
+import "database/sql"
+
+// FileEvent is a change to a file or directory, identified by the activity that recorded it.
+// Changes made outside the panel have no activity and an ActivityID of zero.
+type FileEvent struct {
+	// Seq orders events, including several recorded under the same activity
+	Seq        int64  `json:"-"`
+	ActivityID int64  `json:"id"`
+	Timestamp  string `json:"timestamp"`
+	Event      string `json:"event"`
+	Path       string `json:"path"`
+	OldPath    string `json:"old_path,omitempty"`
+	IsDir      bool   `json:"is_dir"`
+}
+
+// RecordFileEvent stores a file event under its activity and returns its sequence number
+func RecordFileEvent(e FileEvent) (int64, error) {
+	res, err := exec(
+		"INSERT INTO file_events (activity_id, event, path, old_path, is_dir) VALUES (?, ?, ?, ?, ?)",
+		e.ActivityID, e.Event, e.Path, e.OldPath, e.IsDir,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetFileEventsSince retrieves events recorded after the one numbered seq, oldest first
+func GetFileEventsSince(seq int64, limit int) ([]FileEvent, error) {
+	rows, err := query(
+		`SELECT id, activity_id, timestamp, event, path, old_path, is_dir FROM file_events
+		 WHERE id > ? ORDER BY id LIMIT ?`,
+		seq, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []FileEvent
+	for rows.Next() {
+		var e FileEvent
+		if err := rows.Scan(&e.Seq, &e.ActivityID, &e.Timestamp, &e.Event, &e.Path, &e.OldPath, &e.IsDir); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// PruneFileEvents deletes all but the most recent keep events, given the newest sequence number
+func PruneFileEvents(newest int64, keep int) error {
+	_, err := exec("DELETE FROM file_events WHERE id <= ?", newest-int64(keep))
+	return err
+}
+
+// GetOldestFileEventSeq returns the sequence number of the oldest retained event, or 0 when there are none
+func GetOldestFileEventSeq() (int64, error) {
+	var seq sql.NullInt64
+	if err := queryRow("SELECT MIN(id) FROM file_events").Scan(&seq); err != nil {
+		return 0, err
+	}
+	return seq.Int64, nil
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"hextech-panel/metrics"
+)
+
+var queryDuration = metrics.NewHistogram("hextech_db_query_duration_seconds",
+	"Time taken by database queries, by statement and table.",
+	[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 5},
+	"statement", "table")
+
+// tablePattern finds the table a statement reads from or writes to
+var tablePattern = regexp.MustCompile(`(?is)\b(?:from|into|update)\s+(\w+)`)
+
+// queryLabels caches the statement and table labels of each distinct query string
+var queryLabels sync.Map
+
+// labelsFor returns the statement kind and main table of a query
+func labelsFor(query string) []string {
+	if labels, ok := queryLabels.Load(query); ok {
+		return labels.([]string)
+	}
+	statement, table := "other", "unknown"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		statement = strings.ToLower(fields[0])
+	}
+	if m := tablePattern.FindStringSubmatch(query); m != nil {
+		table = m[1]
+	}
+	labels := []string{statement, table}
+	queryLabels.Store(query, labels)
+	return labels
+}
+
+// exec runs a statement, recording its latency
+func exec(stmt string, args ...any) (sql.Result, error) {
+	defer queryDuration.ObserveSince(time.Now(), labelsFor(stmt)...)
+	return database.Exec(stmt, args...)
+}
+
+// query runs a query returning rows, recording the latency until its first row is ready
+func query(stmt string, args ...any) (*sql.Rows, error) {
+	defer queryDuration.ObserveSince(time.Now(), labelsFor(stmt)...)
+	return database.Query(stmt, args...)
+}
+
+// queryRow runs a query returning at most one row, recording its latency
+func queryRow(stmt string, args ...any) *sql.Row {
+	defer queryDuration.ObserveSince(time.Now(), labelsFor(stmt)...)
+	return database.QueryRow(stmt, args...)
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"hextech-panel/imaging"
+)
+
+// activityActions lists the actions accepted by the activity_log CHECK constraint.
+// Keep in sync with schema.sql; databases created before an action was added
+// are rebuilt by migrateActivityLog.
+var activityActions = []string{
+	"upload", "rename", "move", "replace", "delete",
+	"extract", "batch", "copy", "mkdir",
+}
+
+// migrate brings an existing database up to date with schema.sql
+func migrate() error {
+	if err := migrateActivityLog(); err != nil {
+		return err
+	}
+	if err := migratePresetQuality(); err != nil {
+		return err
+	}
+	return migrateBlockedExtensions()
+}
+
+// seededBlockedExtensions is the blocked_extensions value databases used to be created with
+const seededBlockedExtensions = "php,phtml,phar,cgi,pl,py,sh,exe,dll,so,bin,bat,cmd,ps1"
+
+// migrateBlockedExtensions clears a blocked_extensions setting still at the value it was
+// created with, so that BLOCKED_EXTENSIONS applies until an administrator changes it
+func migrateBlockedExtensions() error {
+	_, err := database.Exec("UPDATE settings SET value = '' WHERE key = 'blocked_extensions' AND value = ?", seededBlockedExtensions)
+	return err
+}
+
+// migratePresetQuality gives presets saved without a quality the default one explicitly,
+// so transform URLs, which now always carry a quality, keep matching them
+func migratePresetQuality() error {
+	_, err := database.Exec("UPDATE image_presets SET quality = ? WHERE quality = 0", imaging.DefaultQuality)
+	return err
+}
+
+// migrateActivityLog rebuilds activity_log when its CHECK constraint predates newer actions.
+// SQLite cannot alter a CHECK constraint in place, so the table is copied.
+func migrateActivityLog() error {
+	var ddl string
+	if err := database.QueryRow(
+		"SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'activity_log'",
+	).Scan(&ddl); err != nil {
+		return err
+	}
+
+	quoted := make([]string, len(activityActions))
+	upToDate := true
+	for i, a := range activityActions {
+		quoted[i] = "'" + a + "'"
+		if !strings.Contains(ddl, quoted[i]) {
+			upToDate = false
+		}
+	}
+	if upToDate {
+		return nil
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE activity_log_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    action TEXT NOT NULL CHECK(action IN (%s)),
+    file_path TEXT NOT NULL,
+    source_ip TEXT
+)`, strings.Join(quoted, ", ")),
+		"INSERT INTO activity_log_new (id, timestamp, action, file_path, source_ip) SELECT id, timestamp, action, file_path, source_ip FROM activity_log",
+		"DROP TABLE activity_log",
+		"ALTER TABLE activity_log_new RENAME TO activity_log",
+		"CREATE INDEX IF NOT EXISTS idx_activity_log_timestamp ON activity_log(timestamp DESC)",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,64 @@
+package db
+
+import "hextech-panel/imaging"
+
+// ImagePreset is a named, allowlisted set of transform options
+type ImagePreset struct {
+	Name string `json:"name"`
+	imaging.TransformOptions
+}
+
+// GetImagePresets retrieves all image transform presets
+func GetImagePresets() ([]ImagePreset, error) {
+	rows, err := query("SELECT name, width, height, fit, format, quality FROM image_presets ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var presets []ImagePreset
+	for rows.Next() {
+		var p ImagePreset
+		if err := rows.Scan(&p.Name, &p.Width, &p.Height, &p.Fit, &p.Format, &p.Quality); err != nil {
+			return nil, err
+		}
+		presets = append(presets, p)
+	}
+	return presets, rows.Err()
+}
+
+// GetImagePreset retrieves a preset by name
+func GetImagePreset(name string) (ImagePreset, error) {
+	p := ImagePreset{Name: name}
+	err := queryRow(
+		"SELECT width, height, fit, format, quality FROM image_presets WHERE name = ?", name,
+	).Scan(&p.Width, &p.Height, &p.Fit, &p.Format, &p.Quality)
+	return p, err
+}
+
+// FindImagePreset retrieves the preset whose options exactly match o
+func FindImagePreset(o imaging.TransformOptions) (ImagePreset, error) {
+	p := ImagePreset{TransformOptions: o}
+	err := queryRow(
+		`SELECT name FROM image_presets
+		 WHERE width = ? AND height = ? AND fit = ? AND format = ? AND quality = ?
+		 LIMIT 1`,
+		o.Width, o.Height, o.Fit, o.Format, o.Quality,
+	).Scan(&p.Name)
+	return p, err
+}
+
+// SetImagePreset creates or updates a preset
+func SetImagePreset(p ImagePreset) error {
+	_, err := exec(
+		"INSERT OR REPLACE INTO image_presets (name, width, height, fit, format, quality) VALUES (?, ?, ?, ?, ?, ?)",
+		p.Name, p.Width, p.Height, p.Fit, p.Format, p.Quality,
+	)
+	return err
+}
+
+// DeleteImagePreset removes a preset
+func DeleteImagePreset(name string) error {
+	_, err := exec("DELETE FROM image_presets WHERE name = ?", name)
+	return err
+}
@@ -0,0 +1,140 @@
+package db
+
+// Quota represents a storage quota on a directory subtree or a user
+type Quota struct {
+	ID        int64  `json:"id"`
+	Scope     string `json:"scope"`
+	Target    string `json:"target"`
+	MaxBytes  int64  `json:"max_bytes"`
+	MaxFiles  int64  `json:"max_files"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Quota scopes
+const (
+	QuotaScopeDirectory = "directory"
+	QuotaScopeUser      = "user"
+)
+
+// GetQuotas retrieves all configured quotas
+func GetQuotas() ([]Quota, error) {
+	rows, err := query(
+		"SELECT id, scope, target, max_bytes, max_files, created_at FROM quotas ORDER BY scope, target",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotas []Quota
+	for rows.Next() {
+		var q Quota
+		if err := rows.Scan(&q.ID, &q.Scope, &q.Target, &q.MaxBytes, &q.MaxFiles, &q.CreatedAt); err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, rows.Err()
+}
+
+// SetQuota creates or updates the quota for a scope and target
+func SetQuota(scope, target string, maxBytes, maxFiles int64) error {
+	_, err := exec(
+		`INSERT INTO quotas (scope, target, max_bytes, max_files) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(scope, target) DO UPDATE SET max_bytes = excluded.max_bytes, max_files = excluded.max_files`,
+		scope, target, maxBytes, maxFiles,
+	)
+	return err
+}
+
+// DeleteQuota removes a quota by ID
+func DeleteQuota(id int64) error {
+	_, err := exec("DELETE FROM quotas WHERE id = ?", id)
+	return err
+}
+
+// SetFileOwner records the owner and size of a file
+func SetFileOwner(path, owner string, size int64) error {
+	_, err := exec(
+		"INSERT OR REPLACE INTO file_owners (path, owner, size, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)",
+		path, owner, size,
+	)
+	return err
+}
+
+// GetFileOwner retrieves the recorded owner of a file
+func GetFileOwner(path string) (string, error) {
+	var owner string
+	err := queryRow("SELECT owner FROM file_owners WHERE path = ?", path).Scan(&owner)
+	return owner, err
+}
+
+// DeleteFileOwners removes ownership records for a path and everything below it
+func DeleteFileOwners(path string) error {
+	_, err := exec(
+		"DELETE FROM file_owners WHERE path = ? OR substr(path, 1, ?) = ?",
+		path, len(path)+1, path+"/",
+	)
+	return err
+}
+
+// MoveFileOwners rewrites ownership records when a path or directory is moved
+func MoveFileOwners(oldPath, newPath string) error {
+	_, err := exec(
+		`UPDATE file_owners SET path = ? || substr(path, ?)
+		 WHERE path = ? OR substr(path, 1, ?) = ?`,
+		newPath, len(oldPath)+1, oldPath, len(oldPath)+1, oldPath+"/",
+	)
+	return err
+}
+
+// FileOwner is the recorded owner and size of a file
+type FileOwner struct {
+	Path  string
+	Owner string
+	Size  int64
+}
+
+// TakeFileOwners removes and returns the ownership records for a path and everything below it
+func TakeFileOwners(path string) ([]FileOwner, error) {
+	rows, err := query(
+		"SELECT path, owner, size FROM file_owners WHERE path = ? OR substr(path, 1, ?) = ?",
+		path, len(path)+1, path+"/",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []FileOwner
+	for rows.Next() {
+		var o FileOwner
+		if err := rows.Scan(&o.Path, &o.Owner, &o.Size); err != nil {
+			return nil, err
+		}
+		owners = append(owners, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return owners, DeleteFileOwners(path)
+}
+
+// RestoreFileOwners puts back ownership records returned by TakeFileOwners
+func RestoreFileOwners(owners []FileOwner) error {
+	for _, o := range owners {
+		if err := SetFileOwner(o.Path, o.Owner, o.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserUsage returns the total bytes and file count owned by a user
+func GetUserUsage(owner string) (bytes int64, files int64, err error) {
+	err = queryRow(
+		"SELECT COALESCE(SUM(size), 0), COUNT(*) FROM file_owners WHERE owner = ?",
+		owner,
+	).Scan(&bytes, &files)
+	return
+}
@@ -0,0 +1,80 @@
+package db
+
+import "strings"
+
+// UploadRule restricts what may be written into a directory subtree
+type UploadRule struct {
+	ID              int64    `json:"id"`
+	Target          string   `json:"target"`
+	ExtensionMode   string   `json:"extension_mode"`
+	Extensions      []string `json:"extensions"`
+	MIMEMode        string   `json:"mime_mode"`
+	MIMETypes       []string `json:"mime_types"`
+	MaxSize         int64    `json:"max_size"`
+	FilenamePattern string   `json:"filename_pattern"`
+	CreatedAt       string   `json:"created_at"`
+}
+
+// Upload rule list modes
+const (
+	RuleModeAllow = "allow"
+	RuleModeBlock = "block"
+)
+
+// splitList parses a stored comma-separated list
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// GetUploadRules retrieves all upload rules
+func GetUploadRules() ([]UploadRule, error) {
+	rows, err := query(
+		`SELECT id, target, extension_mode, extensions, mime_mode, mime_types, max_size, filename_pattern, created_at
+		 FROM upload_rules ORDER BY target`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []UploadRule
+	for rows.Next() {
+		var r UploadRule
+		var exts, mimes string
+		if err := rows.Scan(&r.ID, &r.Target, &r.ExtensionMode, &exts, &r.MIMEMode, &mimes,
+			&r.MaxSize, &r.FilenamePattern, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.Extensions = splitList(exts)
+		r.MIMETypes = splitList(mimes)
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// SetUploadRule creates or updates the rule for a target directory
+func SetUploadRule(r UploadRule) error {
+	_, err := exec(
+		`INSERT INTO upload_rules (target, extension_mode, extensions, mime_mode, mime_types, max_size, filename_pattern)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(target) DO UPDATE SET
+		   extension_mode = excluded.extension_mode, extensions = excluded.extensions,
+		   mime_mode = excluded.mime_mode, mime_types = excluded.mime_types,
+		   max_size = excluded.max_size, filename_pattern = excluded.filename_pattern`,
+		r.Target, r.ExtensionMode, strings.Join(r.Extensions, ","), r.MIMEMode, strings.Join(r.MIMETypes, ","),
+		r.MaxSize, r.FilenamePattern,
+	)
+	return err
+}
+
+// DeleteUploadRule removes an upload rule by ID
+func DeleteUploadRule(id int64) error {
+	_, err := exec("DELETE FROM upload_rules WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,59 @@
+package db
+
+import "database/sql"
+
+// Scan result statuses
+const (
+	ScanClean    = "clean"
+	ScanInfected = "infected"
+	ScanError    = "error"
+)
+
+// ScanResult records the outcome of scanning an upload
+type ScanResult struct {
+	ID             int64  `json:"id"`
+	Timestamp      string `json:"timestamp"`
+	Path           string `json:"path"`
+	SHA256         string `json:"sha256"`
+	Status         string `json:"status"`
+	Detail         string `json:"detail,omitempty"`
+	QuarantinePath string `json:"quarantine_path,omitempty"`
+	SourceIP       string `json:"source_ip,omitempty"`
+}
+
+// RecordScan stores a scan result
+func RecordScan(r ScanResult) error {
+	_, err := exec(
+		`INSERT INTO scan_results (path, sha256, status, detail, quarantine_path, source_ip)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		r.Path, r.SHA256, r.Status, r.Detail, r.QuarantinePath, r.SourceIP,
+	)
+	return err
+}
+
+// GetScans retrieves scan results with pagination, optionally filtered by status
+func GetScans(status string, limit, offset int) ([]ScanResult, error) {
+	rows, err := query(
+		`SELECT id, timestamp, path, sha256, status, detail, quarantine_path, source_ip
+		 FROM scan_results WHERE ? = '' OR status = ?
+		 ORDER BY id DESC LIMIT ? OFFSET ?`,
+		status, status, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ScanResult
+	for rows.Next() {
+		var r ScanResult
+		var sourceIP sql.NullString
+		if err := rows.Scan(&r.ID, &r.Timestamp, &r.Path, &r.SHA256, &r.Status, &r.Detail,
+			&r.QuarantinePath, &sourceIP); err != nil {
+			return nil, err
+		}
+		r.SourceIP = sourceIP.String
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
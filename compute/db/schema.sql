@@ -11,7 +11,7 @@ CREATE TABLE IF NOT EXISTS file_metadata (
 CREATE TABLE IF NOT EXISTS activity_log (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-    action TEXT NOT NULL CHECK(action IN ('upload', 'rename', 'move', 'replace', 'delete')),
+    action TEXT NOT NULL CHECK(action IN ('upload', 'rename', 'move', 'replace', 'delete', 'extract', 'batch', 'copy', 'mkdir')),
     file_path TEXT NOT NULL,
     source_ip TEXT
 );
@@ -27,8 +27,120 @@ CREATE TABLE IF NOT EXISTS settings (
 INSERT OR IGNORE INTO settings (key, value) VALUES 
     ('base_directory', ''),
     ('max_upload_size', '104857600'),
-    ('blocked_extensions', 'php,phtml,phar,cgi,pl,py,sh,exe,dll,so,bin,bat,cmd,ps1'),
-    ('public_hostname', '');
+    ('blocked_extensions', ''),
+    ('public_hostname', ''),
+    ('filename_case', 'lower'),
+    ('filename_charset', 'ascii'),
+    ('filename_space_replacement', '-'),
+    ('filename_invalid_replacement', '');
 
 -- Index for faster log queries
 CREATE INDEX IF NOT EXISTS idx_activity_log_timestamp ON activity_log(timestamp DESC);
+
+-- Storage quotas on directory subtrees ('directory') or authenticated users ('user')
+-- A limit of 0 means unlimited for that dimension
+CREATE TABLE IF NOT EXISTS quotas (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope TEXT NOT NULL CHECK(scope IN ('directory', 'user')),
+    target TEXT NOT NULL,
+    max_bytes INTEGER NOT NULL DEFAULT 0,
+    max_files INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(scope, target)
+);
+
+-- File ownership, used to account per-user quota usage
+CREATE TABLE IF NOT EXISTS file_owners (
+    path TEXT PRIMARY KEY,
+    owner TEXT NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_owners_owner ON file_owners(owner);
+
+-- Allowlist of image transform presets served by the public /transform endpoint
+CREATE TABLE IF NOT EXISTS image_presets (
+    name TEXT PRIMARY KEY,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    fit TEXT NOT NULL DEFAULT 'contain' CHECK(fit IN ('contain', 'cover', 'fill')),
+    format TEXT NOT NULL DEFAULT '',
+    quality INTEGER NOT NULL DEFAULT 85
+);
+
+-- Upload rules on directory subtrees; every rule covering a path applies.
+-- Lists are comma-separated and an empty list leaves that dimension unrestricted.
+-- A max_size of 0 means unlimited; filename_pattern is a regular expression the whole filename must match.
+CREATE TABLE IF NOT EXISTS upload_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target TEXT NOT NULL UNIQUE,
+    extension_mode TEXT NOT NULL DEFAULT 'block' CHECK(extension_mode IN ('allow', 'block')),
+    extensions TEXT NOT NULL DEFAULT '',
+    mime_mode TEXT NOT NULL DEFAULT 'block' CHECK(mime_mode IN ('allow', 'block')),
+    mime_types TEXT NOT NULL DEFAULT '',
+    max_size INTEGER NOT NULL DEFAULT 0,
+    filename_pattern TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Virus scan results for uploaded content; infected files are kept in quarantine_path
+-- 'error' means the scanner was unavailable or could not scan the file
+CREATE TABLE IF NOT EXISTS scan_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    path TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('clean', 'infected', 'error')),
+    detail TEXT NOT NULL DEFAULT '',
+    quarantine_path TEXT NOT NULL DEFAULT '',
+    source_ip TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_results_timestamp ON scan_results(timestamp DESC);
+
+-- Outgoing webhooks. events and path_prefixes are comma-separated; an empty list matches everything.
+CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL DEFAULT '',
+    path_prefixes TEXT NOT NULL DEFAULT '',
+    active INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Webhook delivery queue and history. Pending deliveries are retried with backoff until
+-- next_attempt_at; a redelivery is a new row pointing at the delivery it repeats.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    response_status INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    redelivery_of INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    completed_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id DESC);
+
+-- Structured file change events for live updates. Each belongs to the activity_log entry
+-- that recorded it; a batch produces several events under one activity.
+CREATE TABLE IF NOT EXISTS file_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    activity_id INTEGER NOT NULL,
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    event TEXT NOT NULL,
+    path TEXT NOT NULL,
+    old_path TEXT NOT NULL DEFAULT '',
+    is_dir INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_events_activity ON file_events(activity_id);
@@ -0,0 +1,230 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint notified of file events
+type Webhook struct {
+	ID           int64    `json:"id"`
+	URL          string   `json:"url"`
+	Secret       string   `json:"-"`
+	Events       []string `json:"events"`
+	PathPrefixes []string `json:"path_prefixes"`
+	Active       bool     `json:"active"`
+	CreatedAt    string   `json:"created_at"`
+}
+
+// WebhookDelivery is a queued or completed delivery of an event to a webhook
+type WebhookDelivery struct {
+	ID             int64  `json:"id"`
+	WebhookID      int64  `json:"webhook_id"`
+	EventID        string `json:"event_id"`
+	Event          string `json:"event"`
+	Payload        string `json:"payload"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	NextAttemptAt  string `json:"next_attempt_at,omitempty"`
+	ResponseStatus int    `json:"response_status,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	RedeliveryOf   int64  `json:"redelivery_of,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	CompletedAt    string `json:"completed_at,omitempty"`
+}
+
+const webhookColumns = "id, url, secret, events, path_prefixes, active, created_at"
+
+// scanWebhook reads a webhook row selected with webhookColumns
+func scanWebhook(row interface{ Scan(...any) error }) (Webhook, error) {
+	var h Webhook
+	var events, prefixes string
+	if err := row.Scan(&h.ID, &h.URL, &h.Secret, &events, &prefixes, &h.Active, &h.CreatedAt); err != nil {
+		return h, err
+	}
+	h.Events = splitList(events)
+	h.PathPrefixes = splitList(prefixes)
+	return h, nil
+}
+
+// GetWebhooks retrieves all webhooks
+func GetWebhooks() ([]Webhook, error) {
+	rows, err := query("SELECT " + webhookColumns + " FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		h, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// GetWebhook retrieves a webhook by ID, returning sql.ErrNoRows if it doesn't exist
+func GetWebhook(id int64) (Webhook, error) {
+	return scanWebhook(queryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+}
+
+// CreateWebhook stores a new webhook and returns its ID
+func CreateWebhook(h Webhook) (int64, error) {
+	res, err := exec(
+		"INSERT INTO webhooks (url, secret, events, path_prefixes, active) VALUES (?, ?, ?, ?, ?)",
+		h.URL, h.Secret, strings.Join(h.Events, ","), strings.Join(h.PathPrefixes, ","), h.Active,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateWebhook replaces a webhook's settings, returning sql.ErrNoRows if it doesn't exist
+func UpdateWebhook(h Webhook) error {
+	res, err := exec(
+		"UPDATE webhooks SET url = ?, secret = ?, events = ?, path_prefixes = ?, active = ? WHERE id = ?",
+		h.URL, h.Secret, strings.Join(h.Events, ","), strings.Join(h.PathPrefixes, ","), h.Active, h.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook and its delivery history
+func DeleteWebhook(id int64) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM webhooks WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const deliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, next_attempt_at,
+	response_status, last_error, redelivery_of, created_at, completed_at`
+
+// scanDelivery reads a delivery row selected with deliveryColumns
+func scanDelivery(row interface{ Scan(...any) error }) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	var next, completed sql.NullString
+	var redeliveryOf sql.NullInt64
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &next,
+		&d.ResponseStatus, &d.LastError, &redeliveryOf, &d.CreatedAt, &completed)
+	if d.Status == DeliveryPending {
+		d.NextAttemptAt = next.String
+	}
+	d.CompletedAt = completed.String
+	d.RedeliveryOf = redeliveryOf.Int64
+	return d, err
+}
+
+// EnqueueDelivery queues an event payload for delivery to a webhook
+func EnqueueDelivery(webhookID int64, eventID, event, payload string) error {
+	_, err := exec(
+		"INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload) VALUES (?, ?, ?, ?)",
+		webhookID, eventID, event, payload,
+	)
+	return err
+}
+
+// GetDueDeliveries retrieves pending deliveries to active webhooks whose next attempt is due, oldest first
+func GetDueDeliveries(limit int) ([]WebhookDelivery, error) {
+	rows, err := query(
+		"SELECT "+deliveryColumns+` FROM webhook_deliveries
+		 WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+		   AND webhook_id IN (SELECT id FROM webhooks WHERE active = 1)
+		 ORDER BY next_attempt_at, id LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordDeliveryAttempt stores the outcome of an attempt. A pending delivery is
+// retried after retryAfter seconds; any other status completes it.
+func RecordDeliveryAttempt(id int64, status string, responseStatus int, lastError string, retryAfter int64) error {
+	_, err := exec(
+		`UPDATE webhook_deliveries SET
+		   status = ?, attempts = attempts + 1, response_status = ?, last_error = ?,
+		   next_attempt_at = datetime('now', '+' || ? || ' seconds'),
+		   completed_at = CASE WHEN ? = 'pending' THEN NULL ELSE CURRENT_TIMESTAMP END
+		 WHERE id = ?`,
+		status, responseStatus, lastError, retryAfter, status, id,
+	)
+	return err
+}
+
+// GetDeliveries retrieves delivery history with pagination, optionally filtered by webhook and status
+func GetDeliveries(webhookID int64, status string, limit, offset int) ([]WebhookDelivery, error) {
+	rows, err := query(
+		"SELECT "+deliveryColumns+` FROM webhook_deliveries
+		 WHERE (? = 0 OR webhook_id = ?) AND (? = '' OR status = ?)
+		 ORDER BY id DESC LIMIT ? OFFSET ?`,
+		webhookID, webhookID, status, status, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Redeliver queues a copy of a delivery's payload, returning the new delivery's ID
+// or sql.ErrNoRows if the original doesn't exist
+func Redeliver(id int64) (int64, error) {
+	res, err := exec(
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, redelivery_of)
+		 SELECT webhook_id, event_id, event, payload, id FROM webhook_deliveries WHERE id = ?`,
+		id,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return res.LastInsertId()
+}
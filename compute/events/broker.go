@@ -0,0 +1,114 @@
+package events
+
+import (
+	"strings"
+	"sync"
+
+	"hextech-panel/db"
+)
+
+// bufferSize is how many events a subscriber may fall behind before it is dropped
+const bufferSize = 256
+
+// Subscription receives the events under a set of directories. C is closed when the
+// subscriber falls too far behind; it should reconnect and resume from its last event.
+type Subscription struct {
+	C    <-chan db.FileEvent
+	ch   chan db.FileEvent
+	dirs []string
+}
+
+// Matches reports whether an event touches one of the subscribed directories or anything below them
+func (s *Subscription) Matches(e db.FileEvent) bool {
+	for _, dir := range s.dirs {
+		if within(dir, e.Path) || (e.OldPath != "" && within(dir, e.OldPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether relPath is dir itself or located beneath it
+func within(dir, relPath string) bool {
+	if dir == "/" {
+		return true
+	}
+	return relPath == dir || strings.HasPrefix(relPath, dir+"/")
+}
+
+// Broker fans file events out to live subscribers
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewBroker creates a broker with no subscribers
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber for events under dirs, which must be clean relative paths.
+// After Close, the subscription's channel is closed straight away.
+func (b *Broker) Subscribe(dirs []string) *Subscription {
+	ch := make(chan db.FileEvent, bufferSize)
+	s := &Subscription{C: ch, ch: ch, dirs: dirs}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Unsubscribe removes a subscriber
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Publish delivers an event to every matching subscriber without blocking.
+// Subscribers whose buffer is full are dropped rather than slowing the publisher.
+func (b *Broker) Publish(e db.FileEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subs {
+		if !s.Matches(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// Close disconnects every subscriber, so that open streams don't hold up a shutdown
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Subscribers returns the number of connected subscribers
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
@@ -0,0 +1,308 @@
+package events
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"hextech-panel/db"
+)
+
+// settleDelay is how long a change seen on disk is held before it is published. Changes
+// the panel makes itself are reported by its handlers, and are recognised by being
+// published through Ignore within this time of the change on disk.
+const settleDelay = 2 * time.Second
+
+// Event types for changes made outside the panel, matching those of its handlers
+const (
+	eventUpload  = "upload"
+	eventReplace = "replace"
+	eventDelete  = "delete"
+	eventMkdir   = "mkdir"
+)
+
+// pendingEvent is a change seen on disk waiting to be published
+type pendingEvent struct {
+	event db.FileEvent
+	at    time.Time
+}
+
+// Watcher publishes changes made to a directory tree outside the panel, such as files
+// copied in over SFTP. Several changes to the same path within settleDelay are published
+// as one. Dotfiles are ignored: the panel never creates them for users but uses them
+// for temporary files.
+type Watcher struct {
+	root    string
+	fsw     *fsnotify.Watcher
+	publish func(db.FileEvent)
+
+	mu      sync.Mutex
+	dirs    map[string]bool
+	pending map[string]pendingEvent
+	recent  map[string]time.Time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWatcher watches every directory under root and passes changes to publish, which
+// is called from a single goroutine
+func NewWatcher(root string, publish func(db.FileEvent)) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		root:    filepath.Clean(root),
+		fsw:     fsw,
+		publish: publish,
+		dirs:    make(map[string]bool),
+		pending: make(map[string]pendingEvent),
+		recent:  make(map[string]time.Time),
+		done:    make(chan struct{}),
+	}
+	if err := w.fsw.Add(w.root); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	w.addTree(w.root, false)
+
+	w.wg.Add(1)
+	go w.loop()
+	return w, nil
+}
+
+// Root returns the watched directory
+func (w *Watcher) Root() string {
+	return w.root
+}
+
+// Ignore marks a change the panel has published itself, so the same change seen on
+// disk is not published again. Anything above or below its paths is covered too,
+// such as the directories created for an upload or the contents of a moved directory.
+func (w *Watcher) Ignore(e db.FileEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	w.recent[e.Path] = now
+	if e.OldPath != "" {
+		w.recent[e.OldPath] = now
+	}
+}
+
+// Close stops watching
+func (w *Watcher) Close() {
+	close(w.done)
+	w.fsw.Close()
+	w.wg.Wait()
+}
+
+// loop receives changes from the file system and publishes them once they settle
+func (w *Watcher) loop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(settleDelay / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("File watcher error; changes made outside the panel may be missed", "error", err)
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+// relative returns the panel path of a file under the root, and whether it is one to report
+func (w *Watcher) relative(fullPath string) (string, bool) {
+	rel, err := filepath.Rel(w.root, fullPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	for _, seg := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(seg, ".") {
+			return "", false
+		}
+	}
+	return "/" + filepath.ToSlash(rel), true
+}
+
+// handle queues the change behind a file system event
+func (w *Watcher) handle(ev fsnotify.Event) {
+	relPath, ok := w.relative(ev.Name)
+	if !ok {
+		return
+	}
+
+	switch {
+	case ev.Has(fsnotify.Create):
+		info, err := os.Lstat(ev.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			w.queue(relPath, eventMkdir, true)
+			if err := w.fsw.Add(ev.Name); err != nil {
+				slog.Warn("Failed to watch directory", "path", relPath, "error", err)
+			}
+			// Anything created before the watch was in place would otherwise go unnoticed
+			w.addTree(ev.Name, true)
+			return
+		}
+		w.queue(relPath, eventUpload, false)
+	case ev.Has(fsnotify.Write):
+		w.queue(relPath, eventReplace, false)
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		w.mu.Lock()
+		isDir := w.dirs[relPath]
+		w.mu.Unlock()
+		if isDir {
+			w.forgetTree(relPath)
+		}
+		w.queue(relPath, eventDelete, isDir)
+	}
+}
+
+// addTree watches the directories below dir, queueing what it finds when report is set
+func (w *Watcher) addTree(dir string, report bool) {
+	if relPath, ok := w.relative(dir); ok {
+		w.mu.Lock()
+		w.dirs[relPath] = true
+		w.mu.Unlock()
+	}
+
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return nil
+		}
+		relPath, ok := w.relative(p)
+		if !ok {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			if report {
+				w.queue(relPath, eventUpload, false)
+			}
+			return nil
+		}
+
+		if err := w.fsw.Add(p); err != nil {
+			slog.Warn("Failed to watch directory", "path", relPath, "error", err)
+			return filepath.SkipDir
+		}
+		w.mu.Lock()
+		w.dirs[relPath] = true
+		w.mu.Unlock()
+		if report {
+			w.queue(relPath, eventMkdir, true)
+		}
+		return nil
+	})
+}
+
+// forgetTree stops watching a directory that was removed or moved away, along with
+// everything below it. A moved directory is watched again where it reappears.
+func (w *Watcher) forgetTree(relPath string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for dir := range w.dirs {
+		if within(relPath, dir) {
+			delete(w.dirs, dir)
+			w.fsw.Remove(filepath.Join(w.root, filepath.FromSlash(strings.TrimPrefix(dir, "/"))))
+		}
+	}
+}
+
+// queue records a change to publish once it settles, merging it with an earlier one
+func (w *Watcher) queue(relPath, event string, isDir bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	if prev, ok := w.pending[relPath]; ok {
+		switch {
+		case event == eventReplace && prev.event.Event != eventDelete:
+			// Writes to a new or already changed file add nothing
+			prev.at = now
+			w.pending[relPath] = prev
+			return
+		case event == eventDelete && (prev.event.Event == eventUpload || prev.event.Event == eventMkdir):
+			// Created and removed again before anyone was told
+			delete(w.pending, relPath)
+			return
+		case prev.event.Event == eventDelete && event == eventUpload:
+			event = eventReplace
+		}
+	}
+	w.pending[relPath] = pendingEvent{
+		event: db.FileEvent{
+			Timestamp: now.UTC().Format(time.RFC3339),
+			Event:     event,
+			Path:      relPath,
+			IsDir:     isDir,
+		},
+		at: now,
+	}
+}
+
+// flush publishes the changes that have settled, oldest first, leaving out those the
+// panel published itself
+func (w *Watcher) flush() {
+	w.mu.Lock()
+	now := time.Now()
+	var ready []pendingEvent
+	for relPath, p := range w.pending {
+		if now.Sub(p.at) < settleDelay {
+			continue
+		}
+		delete(w.pending, relPath)
+		if !w.ignored(relPath) {
+			ready = append(ready, p)
+		}
+	}
+	for relPath, at := range w.recent {
+		if now.Sub(at) > 2*settleDelay {
+			delete(w.recent, relPath)
+		}
+	}
+	w.mu.Unlock()
+
+	sort.Slice(ready, func(i, j int) bool { return ready[i].at.Before(ready[j].at) })
+	for _, p := range ready {
+		w.publish(p.event)
+	}
+}
+
+// ignored reports whether a change at relPath is covered by one the panel published.
+// The caller must hold w.mu.
+func (w *Watcher) ignored(relPath string) bool {
+	for changed := range w.recent {
+		if within(changed, relPath) || within(relPath, changed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,369 @@
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported archive formats
+const (
+	FormatZip    = "zip"
+	FormatTarGz  = "tar.gz"
+	FormatTarZst = "tar.zst"
+)
+
+// Compression levels. Levels in between trade speed for size.
+const (
+	LevelDefault = -1
+	LevelStore   = 0
+	LevelBest    = 9
+)
+
+// FailedEntriesName is the archive entry listing files that could not be exported
+const FailedEntriesName = "FAILED_ENTRIES.txt"
+
+// ChecksumsName is the archive entry listing the SHA-256 of every exported file
+const ChecksumsName = "SHA256SUMS"
+
+var (
+	ErrUnsupportedFormat = errors.New("format must be zip, tar.gz or tar.zst")
+	ErrInvalidLevel      = errors.New("compression level must be between 0 (store) and 9 (best)")
+)
+
+// compressedExts lists formats that are already compressed; ZIP stores them as-is
+var compressedExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".avif": true,
+	".mp3": true, ".mp4": true, ".m4a": true, ".webm": true, ".mkv": true, ".mov": true, ".ogg": true,
+	".zip": true, ".gz": true, ".tgz": true, ".zst": true, ".xz": true, ".bz2": true, ".7z": true, ".rar": true,
+	".woff": true, ".woff2": true, ".pdf": true,
+}
+
+// Options controls the archive format and contents
+type Options struct {
+	Format string
+	// Level is 0 (store) to 9 (best), or LevelDefault. tar.zst has no store mode,
+	// so level 0 selects its fastest setting.
+	Level int
+	// Checksums adds a SHA256SUMS manifest to the archive
+	Checksums bool
+	// CachedHash returns the known SHA-256 of a file by relative path, or "" to hash it while archiving
+	CachedHash func(relPath string) string
+}
+
+// Validate fills in defaults and checks the format and level
+func (o *Options) Validate() error {
+	if o.Format == "" {
+		o.Format = FormatZip
+	}
+	switch o.Format {
+	case FormatZip, FormatTarGz, FormatTarZst:
+	default:
+		return ErrUnsupportedFormat
+	}
+	if o.Level != LevelDefault && (o.Level < LevelStore || o.Level > LevelBest) {
+		return ErrInvalidLevel
+	}
+	return nil
+}
+
+// Extension returns the file extension for a format, including the leading dot
+func Extension(format string) string {
+	return "." + format
+}
+
+// ContentType returns the MIME type for a format
+func ContentType(format string) string {
+	switch format {
+	case FormatTarGz:
+		return "application/gzip"
+	case FormatTarZst:
+		return "application/zstd"
+	default:
+		return "application/zip"
+	}
+}
+
+// ProgressFunc receives incremental progress: completed files and bytes written
+type ProgressFunc func(files int, bytes int64)
+
+// progressWriter reports bytes as they pass through
+type progressWriter struct {
+	w          io.Writer
+	onProgress ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if p.onProgress != nil && n > 0 {
+		p.onProgress(0, int64(n))
+	}
+	return n, err
+}
+
+// contextReader stops reading once the context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+// archiveWriter abstracts over the ZIP and tar container formats
+type archiveWriter interface {
+	createDir(name string, modTime time.Time) error
+	createFile(name string, size int64, modTime time.Time) (io.Writer, error)
+	Close() error
+}
+
+// zipArchive writes ZIP entries, storing already-compressed files
+type zipArchive struct {
+	zw    *zip.Writer
+	store bool
+}
+
+func newZipArchive(w io.Writer, level int) *zipArchive {
+	zw := zip.NewWriter(w)
+	if level != LevelDefault && level != LevelStore {
+		zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+	return &zipArchive{zw: zw, store: level == LevelStore}
+}
+
+func (z *zipArchive) createDir(name string, modTime time.Time) error {
+	header := &zip.FileHeader{Name: name + "/", Modified: modTime}
+	header.SetMode(os.ModeDir | 0755)
+	_, err := z.zw.CreateHeader(header)
+	return err
+}
+
+func (z *zipArchive) createFile(name string, size int64, modTime time.Time) (io.Writer, error) {
+	method := zip.Deflate
+	if z.store || compressedExts[strings.ToLower(filepath.Ext(name))] {
+		method = zip.Store
+	}
+	header := &zip.FileHeader{Name: name, Method: method, Modified: modTime}
+	header.SetMode(0644)
+	return z.zw.CreateHeader(header)
+}
+
+func (z *zipArchive) Close() error {
+	return z.zw.Close()
+}
+
+// tarArchive writes tar entries through a stream compressor
+type tarArchive struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+}
+
+func newTarArchive(w io.Writer, format string, level int) (*tarArchive, error) {
+	var compressor io.WriteCloser
+	var err error
+	switch format {
+	case FormatTarGz:
+		gzLevel := gzip.DefaultCompression
+		if level != LevelDefault {
+			gzLevel = level
+		}
+		compressor, err = gzip.NewWriterLevel(w, gzLevel)
+	case FormatTarZst:
+		zstdLevel := zstd.SpeedDefault
+		switch {
+		case level == LevelDefault:
+		case level <= 2:
+			zstdLevel = zstd.SpeedFastest
+		case level <= 5:
+			zstdLevel = zstd.SpeedDefault
+		case level <= 8:
+			zstdLevel = zstd.SpeedBetterCompression
+		default:
+			zstdLevel = zstd.SpeedBestCompression
+		}
+		compressor, err = zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel))
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tarArchive{tw: tar.NewWriter(compressor), compressor: compressor}, nil
+}
+
+func (t *tarArchive) createDir(name string, modTime time.Time) error {
+	return t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  modTime,
+	})
+}
+
+func (t *tarArchive) createFile(name string, size int64, modTime time.Time) (io.Writer, error) {
+	err := t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modTime,
+	})
+	return t.tw, err
+}
+
+func (t *tarArchive) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.compressor.Close()
+}
+
+// Write writes the plan as an archive in the requested format, preserving modification times.
+// Files that cannot be read are added to the plan's failures and listed in
+// FailedEntriesName inside the archive; write errors abort the export.
+func Write(ctx context.Context, w io.Writer, plan *Plan, opts Options, onProgress ProgressFunc) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	var aw archiveWriter
+	if opts.Format == FormatZip {
+		aw = newZipArchive(w, opts.Level)
+	} else {
+		tw, err := newTarArchive(w, opts.Format, opts.Level)
+		if err != nil {
+			return err
+		}
+		aw = tw
+	}
+
+	var sums strings.Builder
+
+	for _, item := range plan.Items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if item.IsDir {
+			if err := aw.createDir(item.ArchivePath, item.ModTime); err != nil {
+				return err
+			}
+			continue
+		}
+
+		hash, err := writeFile(ctx, aw, plan, item, opts, onProgress)
+		if err == errSkipped {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if opts.Checksums {
+			fmt.Fprintf(&sums, "%s  %s\n", hash, item.ArchivePath)
+		}
+		if onProgress != nil {
+			onProgress(1, 0)
+		}
+	}
+
+	if opts.Checksums {
+		if err := writeManifest(aw, ChecksumsName, sums.String()); err != nil {
+			return err
+		}
+	}
+
+	if len(plan.Failures) > 0 {
+		var sb strings.Builder
+		sb.WriteString("The following entries could not be exported:\n\n")
+		for _, f := range plan.Failures {
+			fmt.Fprintf(&sb, "%s: %s\n", f.Path, f.Error)
+		}
+		if err := writeManifest(aw, FailedEntriesName, sb.String()); err != nil {
+			return err
+		}
+	}
+
+	return aw.Close()
+}
+
+// errSkipped marks a file that was recorded as a failure instead of written
+var errSkipped = errors.New("skipped")
+
+// writeFile copies a single file into the archive and returns its SHA-256 when checksums are enabled
+func writeFile(ctx context.Context, aw archiveWriter, plan *Plan, item Item, opts Options, onProgress ProgressFunc) (string, error) {
+	skip := func(err error) (string, error) {
+		plan.Failures = append(plan.Failures, Failure{Path: item.ArchivePath, Error: err.Error()})
+		return "", errSkipped
+	}
+
+	f, err := os.Open(item.FullPath)
+	if err != nil {
+		return skip(err)
+	}
+	defer f.Close()
+
+	// Use the size at open time; tar headers must match the bytes that follow
+	info, err := f.Stat()
+	if err != nil {
+		return skip(err)
+	}
+
+	entry, err := aw.createFile(item.ArchivePath, info.Size(), info.ModTime())
+	if err != nil {
+		return "", err
+	}
+
+	var hash string
+	if opts.Checksums && opts.CachedHash != nil {
+		hash = opts.CachedHash(item.RelPath)
+	}
+
+	dst := io.Writer(&progressWriter{w: entry, onProgress: onProgress})
+	hasher := sha256.New()
+	if opts.Checksums && hash == "" {
+		dst = io.MultiWriter(dst, hasher)
+	}
+
+	n, err := io.CopyN(dst, &contextReader{ctx: ctx, r: f}, info.Size())
+	if err == io.EOF && n < info.Size() {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		// The entry is already partially written, so the archive can't be salvaged
+		return "", fmt.Errorf("%s: %w", item.ArchivePath, err)
+	}
+
+	if opts.Checksums && hash == "" {
+		hash = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return hash, nil
+}
+
+// writeManifest adds a generated text file to the archive
+func writeManifest(aw archiveWriter, name, content string) error {
+	entry, err := aw.createFile(name, int64(len(content)), time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(entry, content)
+	return err
+}
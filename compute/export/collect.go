@@ -0,0 +1,145 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+var ErrLimitExceeded = errors.New("export exceeds the configured limits")
+
+// Limits bounds the size of a single export
+type Limits struct {
+	MaxFiles int
+	MaxBytes int64
+}
+
+// Source is a validated path to export
+type Source struct {
+	// FullPath is the absolute path on disk
+	FullPath string
+	// RelPath is the path relative to the base directory, as requested by the client
+	RelPath string
+}
+
+// Item is a single file or directory to be written to an archive
+type Item struct {
+	FullPath string
+	// RelPath is the path relative to the base directory
+	RelPath     string
+	ArchivePath string
+	IsDir       bool
+	Size        int64
+	ModTime     time.Time
+}
+
+// Failure records an entry that could not be exported
+type Failure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// Plan is the full set of items for an export along with its totals
+type Plan struct {
+	Items      []Item
+	Failures   []Failure
+	TotalFiles int
+	TotalBytes int64
+}
+
+// Collect walks the sources and builds an export plan.
+// Unreadable entries are recorded as failures instead of being skipped silently.
+// Returns ErrLimitExceeded as soon as the plan grows beyond the limits.
+func Collect(sources []Source, limits Limits) (*Plan, error) {
+	plan := &Plan{}
+
+	add := func(item Item) error {
+		plan.Items = append(plan.Items, item)
+		if item.IsDir {
+			return nil
+		}
+		plan.TotalFiles++
+		plan.TotalBytes += item.Size
+		if limits.MaxFiles > 0 && plan.TotalFiles > limits.MaxFiles {
+			return fmt.Errorf("%w: more than %d files", ErrLimitExceeded, limits.MaxFiles)
+		}
+		if limits.MaxBytes > 0 && plan.TotalBytes > limits.MaxBytes {
+			return fmt.Errorf("%w: more than %d bytes", ErrLimitExceeded, limits.MaxBytes)
+		}
+		return nil
+	}
+
+	for _, src := range sources {
+		info, err := os.Lstat(src.FullPath)
+		if err != nil {
+			plan.Failures = append(plan.Failures, Failure{Path: src.RelPath, Error: err.Error()})
+			continue
+		}
+
+		if !info.IsDir() {
+			if !info.Mode().IsRegular() {
+				plan.Failures = append(plan.Failures, Failure{Path: src.RelPath, Error: "not a regular file"})
+				continue
+			}
+			err := add(Item{
+				FullPath:    src.FullPath,
+				RelPath:     src.RelPath,
+				ArchivePath: filepath.Base(src.FullPath),
+				Size:        info.Size(),
+				ModTime:     info.ModTime(),
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		parent := filepath.Dir(src.FullPath)
+		err = filepath.Walk(src.FullPath, func(p string, info os.FileInfo, err error) error {
+			rel, relErr := filepath.Rel(parent, p)
+			if relErr != nil {
+				return relErr
+			}
+			archivePath := filepath.ToSlash(rel)
+			within, relErr := filepath.Rel(src.FullPath, p)
+			if relErr != nil {
+				return relErr
+			}
+
+			if err != nil {
+				plan.Failures = append(plan.Failures, Failure{Path: archivePath, Error: err.Error()})
+				if info != nil && info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Skip symlinks
+			if info.Mode()&os.ModeSymlink != 0 {
+				return nil
+			}
+
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				plan.Failures = append(plan.Failures, Failure{Path: archivePath, Error: "not a regular file"})
+				return nil
+			}
+
+			return add(Item{
+				FullPath:    p,
+				RelPath:     path.Join(src.RelPath, filepath.ToSlash(within)),
+				ArchivePath: archivePath,
+				IsDir:       info.IsDir(),
+				Size:        info.Size(),
+				ModTime:     info.ModTime(),
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,335 @@
+package export
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+var ErrJobNotFound = errors.New("export job not found")
+
+// Job is a snapshot of an export job's state
+type Job struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Paths       []string   `json:"paths"`
+	Filename    string     `json:"filename"`
+	Format      string     `json:"format"`
+	Owner       string     `json:"owner,omitempty"`
+	FilesTotal  int        `json:"files_total"`
+	FilesDone   int        `json:"files_done"`
+	BytesTotal  int64      `json:"bytes_total"`
+	BytesDone   int64      `json:"bytes_done"`
+	ArchiveSize int64      `json:"archive_size,omitempty"`
+	Failures    []Failure  `json:"failures"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// job is the internal, mutable state behind a Job snapshot
+type job struct {
+	Job
+	opts   Options
+	cancel context.CancelFunc
+	file   string
+}
+
+// ManagerConfig configures an export job manager
+type ManagerConfig struct {
+	// Dir holds finished archives; it must not be publicly served
+	Dir string
+	// Limits bounds each export
+	Limits Limits
+	// Concurrency is the number of exports that may run at once
+	Concurrency int
+	// TTL is how long finished jobs and their archives are kept
+	TTL time.Duration
+}
+
+// Manager runs export jobs in the background and cleans up after them
+type Manager struct {
+	cfg  ManagerConfig
+	sem  chan struct{}
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// NewManager creates a manager, clearing archives left behind by a previous run
+func NewManager(cfg ManagerConfig) (*Manager, error) {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Hour
+	}
+
+	// Jobs are held in memory, so archives from a previous process are orphans
+	if err := os.RemoveAll(cfg.Dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		cfg:  cfg,
+		sem:  make(chan struct{}, cfg.Concurrency),
+		ctx:  ctx,
+		stop: stop,
+		jobs: make(map[string]*job),
+	}
+
+	m.wg.Add(1)
+	go m.cleanupLoop()
+	return m, nil
+}
+
+// newJobID returns a random, URL-safe job identifier
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Submit plans an export and queues it. Limit violations are reported immediately.
+func (m *Manager) Submit(sources []Source, opts Options, filename, owner string) (Job, error) {
+	if err := opts.Validate(); err != nil {
+		return Job{}, err
+	}
+
+	plan, err := Collect(sources, m.cfg.Limits)
+	if err != nil {
+		return Job{}, err
+	}
+
+	paths := make([]string, len(sources))
+	for i, s := range sources {
+		paths[i] = s.RelPath
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	j := &job{
+		Job: Job{
+			ID:         newJobID(),
+			Status:     StatusQueued,
+			Paths:      paths,
+			Filename:   filename,
+			Format:     opts.Format,
+			Owner:      owner,
+			FilesTotal: plan.TotalFiles,
+			BytesTotal: plan.TotalBytes,
+			Failures:   []Failure{},
+			CreatedAt:  time.Now().UTC(),
+		},
+		opts:   opts,
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	m.jobs[j.ID] = j
+	snapshot := j.snapshot()
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.run(ctx, j, plan)
+
+	return snapshot, nil
+}
+
+// snapshot copies the public job state; callers must hold m.mu
+func (j *job) snapshot() Job {
+	s := j.Job
+	s.Failures = append([]Failure{}, j.Failures...)
+	return s
+}
+
+// Get returns a snapshot of a job
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// Archive returns the path of a completed job's archive
+func (m *Manager) Archive(id string) (Job, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, "", ErrJobNotFound
+	}
+	return j.snapshot(), j.file, nil
+}
+
+// Cancel stops a job and removes it along with any archive
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	var file string
+	if ok {
+		delete(m.jobs, id)
+		file = j.file
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	j.cancel()
+	if file != "" {
+		os.Remove(file)
+	}
+	return nil
+}
+
+// Shutdown cancels all running jobs and waits for them to stop
+func (m *Manager) Shutdown() {
+	m.stop()
+	m.wg.Wait()
+}
+
+// run executes a job once a concurrency slot is free
+func (m *Manager) run(ctx context.Context, j *job, plan *Plan) {
+	defer m.wg.Done()
+
+	select {
+	case m.sem <- struct{}{}:
+		defer func() { <-m.sem }()
+	case <-ctx.Done():
+		m.finish(j, "", ctx.Err())
+		return
+	}
+
+	m.mu.Lock()
+	j.Status = StatusRunning
+	m.mu.Unlock()
+
+	tmp, err := os.CreateTemp(m.cfg.Dir, ".tmp-*")
+	if err != nil {
+		m.finish(j, "", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	err = Write(ctx, tmp, plan, j.opts, func(files int, bytes int64) {
+		m.mu.Lock()
+		j.FilesDone += files
+		j.BytesDone += bytes
+		j.Failures = plan.Failures
+		m.mu.Unlock()
+	})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		m.finish(j, "", err)
+		return
+	}
+
+	final := filepath.Join(m.cfg.Dir, j.ID+Extension(j.Format))
+	if err := os.Rename(tmp.Name(), final); err != nil {
+		m.finish(j, "", err)
+		return
+	}
+
+	m.mu.Lock()
+	j.Failures = plan.Failures
+	m.mu.Unlock()
+	m.finish(j, final, nil)
+}
+
+// finish records the outcome of a job
+func (m *Manager) finish(j *job, file string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	expires := now.Add(m.cfg.TTL)
+	j.CompletedAt = &now
+	j.ExpiresAt = &expires
+
+	switch {
+	case err == nil:
+		j.Status = StatusCompleted
+		j.file = file
+		if info, statErr := os.Stat(file); statErr == nil {
+			j.ArchiveSize = info.Size()
+		}
+	case errors.Is(err, context.Canceled):
+		j.Status = StatusCancelled
+	default:
+		j.Status = StatusFailed
+		j.Error = err.Error()
+		slog.Error("Export job failed", "job_id", j.ID, "error", err)
+	}
+
+	// A job cancelled via Cancel is no longer tracked; don't leave its archive behind
+	if _, tracked := m.jobs[j.ID]; !tracked && file != "" {
+		os.Remove(file)
+	}
+}
+
+// cleanupLoop periodically removes expired jobs and their archives
+func (m *Manager) cleanupLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.cleanup(time.Now().UTC())
+		}
+	}
+}
+
+// cleanup removes jobs whose expiry has passed
+func (m *Manager) cleanup(now time.Time) {
+	m.mu.Lock()
+	var expired []*job
+	for id, j := range m.jobs {
+		if j.ExpiresAt != nil && now.After(*j.ExpiresAt) {
+			expired = append(expired, j)
+			delete(m.jobs, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, j := range expired {
+		if j.file != "" {
+			os.Remove(j.file)
+		}
+	}
+}
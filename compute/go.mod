@@ -1,9 +1,17 @@
 module hextech-panel
 
-go 1.21
+go 1.22.2
 
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/HugoSmits86/nativewebp v1.2.0
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/go-chi/chi/v5 v5.0.11
 	github.com/go-chi/cors v1.2.1
+	github.com/klauspost/compress v1.18.0
 	github.com/mattn/go-sqlite3 v1.14.19
+	golang.org/x/image v0.24.0
+	golang.org/x/sys v0.4.0
+	golang.org/x/text v0.22.0
+	gopkg.in/yaml.v3 v3.0.1
 )
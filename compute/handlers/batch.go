@@ -0,0 +1,628 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hextech-panel/config"
+	"hextech-panel/db"
+	"hextech-panel/security"
+)
+
+// maxBatchOperations caps the number of operations in a single batch request
+const maxBatchOperations = 500
+
+// maxBatchLogItems caps how many operations are spelled out in the activity log entry
+const maxBatchLogItems = 10
+
+// Batch operation types
+const (
+	batchOpMove   = "move"
+	batchOpCopy   = "copy"
+	batchOpDelete = "delete"
+	batchOpRename = "rename"
+	batchOpMkdir  = "mkdir"
+)
+
+// Batch item statuses
+const (
+	batchStatusOK             = "ok"
+	batchStatusPlanned        = "planned"
+	batchStatusFailed         = "failed"
+	batchStatusSkipped        = "skipped"
+	batchStatusRolledBack     = "rolled_back"
+	batchStatusRollbackFailed = "rollback_failed"
+)
+
+// BatchOperation is a single operation in a batch request
+type BatchOperation struct {
+	Op          string `json:"op"`
+	Path        string `json:"path"`
+	Destination string `json:"destination,omitempty"` // move, copy
+	NewName     string `json:"new_name,omitempty"`    // rename
+	Name        string `json:"name,omitempty"`        // mkdir
+}
+
+// BatchResult reports the outcome of a single batch operation
+type BatchResult struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op"`
+	Path    string `json:"path"`
+	NewPath string `json:"new_path,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	// NameChanges lists how the filename policy altered a mkdir or rename name
+	NameChanges []string `json:"name_changes,omitempty"`
+}
+
+// viewEntry records how a planned operation changed a path
+type viewEntry struct {
+	seq    int
+	exists bool
+	isDir  bool
+	source string // for moved or copied trees, where the contents come from
+}
+
+// batchView overlays the effects of planned operations on the filesystem,
+// so operations can be validated against the state earlier ones leave behind
+type batchView struct {
+	basePath string
+	seq      int
+	entries  map[string][]viewEntry
+}
+
+// stat reports whether a path exists in the view and whether it is a directory
+func (v *batchView) stat(fullPath string) (bool, error) {
+	return v.statBefore(fullPath, v.seq+1)
+}
+
+// statBefore is stat as of just before the operation numbered seq, so moved
+// trees resolve against their source as it was when they were moved
+func (v *batchView) statBefore(fullPath string, seq int) (bool, error) {
+	for cur := fullPath; ; cur = filepath.Dir(cur) {
+		if e, ok := v.latest(cur, seq); ok {
+			switch {
+			case !e.exists:
+				return false, security.ErrInvalidPath
+			case cur == fullPath:
+				return e.isDir, nil
+			case e.source != "":
+				rel, _ := filepath.Rel(cur, fullPath)
+				return v.statBefore(filepath.Join(e.source, rel), e.seq)
+			default:
+				return false, security.ErrInvalidPath
+			}
+		}
+		if cur == v.basePath || cur == filepath.Dir(cur) {
+			break
+		}
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return false, security.ErrInvalidPath
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return false, security.ErrSymlinkDetected
+	}
+	return info.IsDir(), nil
+}
+
+// latest returns the most recent entry for a path recorded before seq
+func (v *batchView) latest(fullPath string, seq int) (viewEntry, bool) {
+	entries := v.entries[fullPath]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].seq < seq {
+			return entries[i], true
+		}
+	}
+	return viewEntry{}, false
+}
+
+// record marks a path as created (optionally from a source tree) or removed
+func (v *batchView) record(fullPath string, exists, isDir bool, source string) {
+	v.entries[fullPath] = append(v.entries[fullPath], viewEntry{seq: v.seq, exists: exists, isDir: isDir, source: source})
+}
+
+// next starts recording a new operation
+func (v *batchView) next() {
+	v.seq++
+}
+
+// batch holds the state of a batch request while it runs
+type batch struct {
+	ctx         context.Context
+	basePath    string
+	blockedExts []string
+	policy      security.FilenamePolicy
+	rules       uploadRules
+	owner       string
+	dryRun      bool
+	atomic      bool
+	view        *batchView
+	undo        []func() error
+	undoIndex   []int
+	deletes     []pendingDelete
+}
+
+// pendingDelete is a path an atomic batch has moved aside, removed once the batch succeeds
+type pendingDelete struct {
+	staging string
+}
+
+// existingPath resolves a path that must exist and is not the base directory
+func (b *batch) existingPath(requested string) (string, bool, error) {
+	fullPath, err := security.ValidatePath(b.basePath, requested)
+	if err != nil {
+		return "", false, err
+	}
+	if fullPath == filepath.Clean(b.basePath) {
+		return "", false, errors.New("the root directory cannot be changed")
+	}
+	isDir, err := b.view.stat(fullPath)
+	if errors.Is(err, security.ErrInvalidPath) {
+		return "", false, errors.New("path not found")
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return fullPath, isDir, nil
+}
+
+// directory resolves a path that must be an existing directory
+func (b *batch) directory(requested string) (string, error) {
+	fullPath, err := security.ValidatePath(b.basePath, requested)
+	if err != nil {
+		return "", err
+	}
+	isDir, err := b.view.stat(fullPath)
+	if err != nil || !isDir {
+		return "", errors.New("destination is not a directory")
+	}
+	return fullPath, nil
+}
+
+// available checks that nothing exists at a destination path
+func (b *batch) available(fullPath string) error {
+	if _, err := b.view.stat(fullPath); err == nil {
+		return errors.New("a file with this name already exists at the destination")
+	}
+	return nil
+}
+
+// checkRules verifies the file or tree at src may be placed at dst under the upload rules.
+// In a dry run, a source that an earlier operation would create isn't on disk to inspect.
+func (b *batch) checkRules(src, dst string) error {
+	if _, err := os.Lstat(src); err != nil && b.dryRun {
+		return nil
+	}
+	_, err := b.rules.checkExisting(b.basePath, src, dst)
+	return err
+}
+
+// plan validates an operation and returns its source and destination paths.
+// Name changes made by the filename policy are recorded on result.
+func (b *batch) plan(op BatchOperation, result *BatchResult) (src, dst string, srcIsDir bool, err error) {
+	switch op.Op {
+	case batchOpMkdir:
+		parent, err := b.directory(op.Path)
+		if err != nil {
+			return "", "", false, err
+		}
+		name, changes, err := b.policy.Normalize(op.Name)
+		if err != nil {
+			return "", "", false, errors.New("invalid directory name: " + err.Error())
+		}
+		result.NameChanges = changes
+		dst = filepath.Join(parent, name)
+		return "", dst, true, b.available(dst)
+
+	case batchOpRename:
+		src, srcIsDir, err = b.existingPath(op.Path)
+		if err != nil {
+			return "", "", false, err
+		}
+		name, changes, err := b.policy.Normalize(op.NewName)
+		if err != nil {
+			return "", "", false, errors.New("invalid new filename: " + err.Error())
+		}
+		result.NameChanges = changes
+		if err := security.ValidateExtension(name, b.blockedExts); err != nil {
+			return "", "", false, errors.New("file type not allowed: " + err.Error())
+		}
+		dst = filepath.Join(filepath.Dir(src), name)
+		if err := b.available(dst); err != nil {
+			return "", "", false, err
+		}
+		return src, dst, srcIsDir, b.checkRules(src, dst)
+
+	case batchOpMove, batchOpCopy:
+		src, srcIsDir, err = b.existingPath(op.Path)
+		if err != nil {
+			return "", "", false, err
+		}
+		dstDir, err := b.directory(op.Destination)
+		if err != nil {
+			return "", "", false, err
+		}
+		dst = filepath.Join(dstDir, filepath.Base(src))
+		if srcIsDir && security.IsInsideDir(src, dst) {
+			return "", "", false, errors.New("cannot " + op.Op + " a directory into itself")
+		}
+		if err := b.available(dst); err != nil {
+			return "", "", false, err
+		}
+		return src, dst, srcIsDir, b.checkRules(src, dst)
+
+	case batchOpDelete:
+		src, srcIsDir, err = b.existingPath(op.Path)
+		return src, "", srcIsDir, err
+
+	default:
+		return "", "", false, errors.New("unknown operation '" + op.Op + "'")
+	}
+}
+
+// simulate records a planned operation in the view
+func (b *batch) simulate(op string, src, dst string, srcIsDir bool) {
+	b.view.next()
+	switch op {
+	case batchOpMkdir:
+		b.view.record(dst, true, true, "")
+	case batchOpRename, batchOpMove:
+		b.view.record(src, false, false, "")
+		b.view.record(dst, true, srcIsDir, src)
+	case batchOpCopy:
+		b.view.record(dst, true, srcIsDir, src)
+	case batchOpDelete:
+		b.view.record(src, false, false, "")
+	}
+}
+
+// pushUndo registers how to reverse a completed operation
+func (b *batch) pushUndo(index int, fn func() error) {
+	b.undo = append(b.undo, fn)
+	b.undoIndex = append(b.undoIndex, index)
+}
+
+// apply performs a validated operation
+func (b *batch) apply(index int, op string, src, dst string) error {
+	srcRel := security.GetRelativePath(b.basePath, src)
+	dstRel := security.GetRelativePath(b.basePath, dst)
+
+	switch op {
+	case batchOpMkdir:
+		if err := os.Mkdir(dst, 0755); err != nil {
+			return errors.New("failed to create directory")
+		}
+		b.pushUndo(index, func() error { return os.Remove(dst) })
+
+	case batchOpRename, batchOpMove:
+		if op == batchOpMove {
+			if _, err := checkMoveQuotas(b.basePath, src, srcRel, dstRel); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return errors.New("failed to " + op + " file")
+		}
+		b.forgetHash(srcRel)
+		if err := db.MoveFileOwners(srcRel, dstRel); err != nil {
+			slog.ErrorContext(b.ctx, "Failed to move file owners", "from", srcRel, "to", dstRel, "error", err)
+			// Quota records must follow the files, so an atomic batch gives up instead
+			if b.atomic {
+				os.Rename(dst, src)
+				return errors.New("failed to " + op + " file")
+			}
+		}
+		b.pushUndo(index, func() error {
+			if err := os.Rename(dst, src); err != nil {
+				return err
+			}
+			return db.MoveFileOwners(dstRel, srcRel)
+		})
+
+	case batchOpCopy:
+		if _, err := checkCopyQuotas(b.basePath, src, dstRel, b.owner); err != nil {
+			return err
+		}
+		if _, err := copyTree(src, dst, conflictFail, copyBookkeeping(b.ctx, b.basePath, b.owner)); err != nil {
+			discardCopy(dst, dstRel)
+			return errors.New("failed to copy file")
+		}
+		b.pushUndo(index, func() error {
+			if err := db.DeleteFileOwners(dstRel); err != nil {
+				return err
+			}
+			return os.RemoveAll(dst)
+		})
+
+	case batchOpDelete:
+		if !b.atomic {
+			return deletePath(b.ctx, b.basePath, src)
+		}
+
+		// Atomic batches move the path aside so a later failure can put it back. It is
+		// staged outside the public tree, so later operations on its parent directory
+		// can neither carry it along nor leave it reachable.
+		stagingRoot := filepath.Join(config.Current.CacheDir, "deleted")
+		if err := os.MkdirAll(stagingRoot, 0755); err != nil {
+			slog.ErrorContext(b.ctx, "Failed to create delete staging directory", "error", err)
+			return errors.New("failed to delete file")
+		}
+		staging, err := os.MkdirTemp(stagingRoot, "batch-*")
+		if err != nil {
+			slog.ErrorContext(b.ctx, "Failed to create delete staging directory", "error", err)
+			return errors.New("failed to delete file")
+		}
+		staged := filepath.Join(staging, filepath.Base(src))
+		if err := moveTree(src, staged); err != nil {
+			slog.ErrorContext(b.ctx, "Failed to stage deleted file", "path", srcRel, "error", err)
+			os.RemoveAll(staging)
+			return errors.New("failed to delete file")
+		}
+		owners, err := db.TakeFileOwners(srcRel)
+		if err != nil {
+			slog.ErrorContext(b.ctx, "Failed to remove file owners", "path", srcRel, "error", err)
+			moveTree(staged, src)
+			os.RemoveAll(staging)
+			return errors.New("failed to delete file")
+		}
+		b.forgetHash(srcRel)
+		b.deletes = append(b.deletes, pendingDelete{staging: staging})
+		b.pushUndo(index, func() error {
+			if err := moveTree(staged, src); err != nil {
+				return err
+			}
+			os.RemoveAll(staging)
+			return db.RestoreFileOwners(owners)
+		})
+	}
+	return nil
+}
+
+// forgetHash drops the cached hashes of a path that no longer holds its content
+func (b *batch) forgetHash(relPath string) {
+	if err := db.DeleteFileHash(relPath); err != nil {
+		slog.ErrorContext(b.ctx, "Failed to delete file hash", "path", relPath, "error", err)
+	}
+}
+
+// commitDeletes removes the paths an atomic batch moved aside
+func (b *batch) commitDeletes() {
+	for _, d := range b.deletes {
+		// The files are already gone from the public tree, so a failure here only
+		// leaves them behind in the cache directory
+		if err := os.RemoveAll(d.staging); err != nil {
+			slog.ErrorContext(b.ctx, "Failed to remove deleted files", "path", d.staging, "error", err)
+		}
+	}
+}
+
+// moveTree renames src to dst, copying the tree across filesystems when renaming fails
+func moveTree(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if _, err := copyTree(src, dst, conflictFail, nil); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// rollback reverses completed operations in reverse order
+func (b *batch) rollback(results []BatchResult) {
+	for i := len(b.undo) - 1; i >= 0; i-- {
+		result := &results[b.undoIndex[i]]
+		if err := b.undo[i](); err != nil {
+			result.Status = batchStatusRollbackFailed
+			result.Error = err.Error()
+			continue
+		}
+		result.Status = batchStatusRolledBack
+	}
+}
+
+// deletePath removes a file or directory tree and its cached metadata
+func deletePath(ctx context.Context, basePath, fullPath string) error {
+	if err := os.RemoveAll(fullPath); err != nil {
+		return errors.New("failed to delete file")
+	}
+	relPath := security.GetRelativePath(basePath, fullPath)
+	if err := db.DeleteFileHash(relPath); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete file hash", "path", relPath, "error", err)
+	}
+	if err := db.DeleteFileOwners(relPath); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete file owners", "path", relPath, "error", err)
+	}
+	return nil
+}
+
+// describeBatch summarizes operations for the activity log
+func describeBatch(results []BatchResult) string {
+	var parts []string
+	for _, res := range results {
+		if res.Status != batchStatusOK {
+			continue
+		}
+		if len(parts) == maxBatchLogItems {
+			parts = append(parts, "...")
+			break
+		}
+		if res.NewPath != "" {
+			parts = append(parts, res.Op+" "+res.Path+" -> "+res.NewPath)
+		} else {
+			parts = append(parts, res.Op+" "+res.Path)
+		}
+	}
+	return fmt.Sprintf("%d operations: %s", len(results), strings.Join(parts, ", "))
+}
+
+// BatchFiles handles running several file operations in one request.
+// With dry_run the operations are only validated; with atomic a failure
+// rolls back every operation that already completed.
+func BatchFiles(w http.ResponseWriter, r *http.Request) {
+	basePath, _, blockedExts, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	var req struct {
+		Operations []BatchOperation `json:"operations"`
+		DryRun     bool             `json:"dry_run"`
+		Atomic     bool             `json:"atomic"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.Operations) == 0 {
+		writeError(w, http.StatusBadRequest, "No operations provided")
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("A batch may contain at most %d operations", maxBatchOperations))
+		return
+	}
+
+	absBase, err := filepath.Abs(basePath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	rules, err := getUploadRules()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load upload rules")
+		return
+	}
+
+	b := &batch{
+		ctx:         r.Context(),
+		basePath:    absBase,
+		blockedExts: blockedExts,
+		policy:      getFilenamePolicy(),
+		rules:       rules,
+		owner:       getUserEmail(r),
+		dryRun:      req.DryRun,
+		atomic:      req.Atomic,
+		view:        &batchView{basePath: absBase, entries: make(map[string][]viewEntry)},
+	}
+
+	results := make([]BatchResult, len(req.Operations))
+	isDir := make([]bool, len(req.Operations))
+	stale := make([][]string, len(req.Operations))
+	failed := false
+	for i, op := range req.Operations {
+		results[i] = BatchResult{Index: i, Op: op.Op, Path: op.Path}
+		result := &results[i]
+
+		if failed && b.atomic {
+			result.Status = batchStatusSkipped
+			continue
+		}
+
+		src, dst, srcIsDir, err := b.plan(op, result)
+		if err == nil && !b.dryRun {
+			if op.Op == batchOpDelete {
+				stale[i] = cachedFiles(absBase, src)
+			}
+			err = b.apply(i, op.Op, src, dst)
+		}
+		if err != nil {
+			result.Status = batchStatusFailed
+			result.Error = err.Error()
+			failed = true
+			continue
+		}
+
+		if src != "" {
+			result.Path = security.GetRelativePath(absBase, src)
+			if dst != "" {
+				result.NewPath = security.GetRelativePath(absBase, dst)
+			}
+		} else {
+			result.Path = security.GetRelativePath(absBase, dst)
+		}
+		result.Status = batchStatusOK
+		isDir[i] = srcIsDir
+		if b.dryRun {
+			result.Status = batchStatusPlanned
+		}
+
+		// In a dry run nothing changes on disk, so later operations see the
+		// tree as this one would leave it through the view
+		if b.dryRun {
+			b.simulate(op.Op, src, dst, srcIsDir)
+		}
+	}
+
+	rolledBack := false
+	if b.atomic && !b.dryRun {
+		if failed {
+			rolledBack = true
+			b.rollback(results)
+		} else {
+			b.commitDeletes()
+		}
+	}
+
+	succeeded := 0
+	for _, res := range results {
+		if res.Status == batchStatusOK || res.Status == batchStatusPlanned {
+			succeeded++
+		}
+	}
+
+	if !b.dryRun && succeeded > 0 {
+		activityID := logActivity(r.Context(), "batch", describeBatch(results), getClientIP(r))
+		for i, res := range results {
+			if res.Status != batchStatusOK {
+				continue
+			}
+			if res.NewPath != "" {
+				publishEvent(r, activityID, res.Op, res.NewPath, res.Path, isDir[i])
+			} else {
+				publishEvent(r, activityID, res.Op, res.Path, "", isDir[i])
+			}
+
+			switch res.Op {
+			case batchOpMove, batchOpRename:
+				purgeMoved(absBase, res.Path, res.NewPath, filepath.Join(absBase, filepath.FromSlash(res.NewPath)))
+			case batchOpDelete:
+				purgeCache(stale[i]...)
+			}
+		}
+	}
+
+	status := http.StatusOK
+	message := "Batch completed"
+	switch {
+	case b.dryRun:
+		message = "Dry run completed"
+	case rolledBack:
+		status = http.StatusConflict
+		message = "Batch failed and was rolled back"
+	case failed:
+		message = "Batch completed with errors"
+	}
+
+	writeJSON(w, status, map[string]interface{}{
+		"message":   message,
+		"dry_run":   b.dryRun,
+		"atomic":    b.atomic,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	})
+}
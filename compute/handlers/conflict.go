@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"hextech-panel/db"
+	"hextech-panel/security"
+)
+
+// Conflict strategies for writes that land on an existing path
+const (
+	conflictFail                 = "fail"
+	conflictSkip                 = "skip"
+	conflictOverwrite            = "overwrite"
+	conflictKeepBoth             = "keep_both"
+	conflictOverwriteIfNewer     = "overwrite_if_newer"
+	conflictOverwriteIfDifferent = "overwrite_if_different"
+)
+
+// Conflict outcomes reported to clients
+const (
+	outcomeCreated     = "created"
+	outcomeOverwritten = "overwritten"
+	outcomeSkipped     = "skipped"
+	outcomeKeptBoth    = "kept_both"
+)
+
+var errConflictStrategy = errors.New("on_conflict must be fail, skip, overwrite, keep_both, overwrite_if_newer or overwrite_if_different")
+
+// parseConflictStrategy validates an on_conflict value, defaulting to fail
+func parseConflictStrategy(value string) (string, error) {
+	switch value {
+	case "":
+		return conflictFail, nil
+	case conflictFail, conflictSkip, conflictOverwrite, conflictKeepBoth,
+		conflictOverwriteIfNewer, conflictOverwriteIfDifferent:
+		return value, nil
+	default:
+		return "", errConflictStrategy
+	}
+}
+
+// incoming describes the content about to be written, for the conditional strategies
+type incoming struct {
+	isDir   bool
+	modTime time.Time
+	hash    func() (string, error)
+}
+
+// resolveConflict decides where a write to dst should go under the given strategy.
+// It returns the final path and the outcome; a skipped write leaves finalPath at dst.
+func resolveConflict(ctx context.Context, basePath, dst, strategy string, in incoming) (finalPath, outcome string, status int, err error) {
+	existing, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return dst, outcomeCreated, http.StatusOK, nil
+	}
+	if err != nil {
+		return "", "", http.StatusInternalServerError, errors.New("failed to check destination")
+	}
+
+	switch strategy {
+	case conflictSkip:
+		return dst, outcomeSkipped, http.StatusOK, nil
+	case conflictKeepBoth:
+		finalPath, err := uniqueName(dst, in.isDir)
+		if err != nil {
+			return "", "", http.StatusConflict, err
+		}
+		return finalPath, outcomeKeptBoth, http.StatusOK, nil
+	case conflictOverwrite, conflictOverwriteIfNewer, conflictOverwriteIfDifferent:
+	default:
+		return "", "", http.StatusConflict, errors.New("a file with this name already exists")
+	}
+
+	// Only regular files are ever replaced; directories would need a merge
+	if !existing.Mode().IsRegular() || in.isDir {
+		return "", "", http.StatusConflict, errors.New("only files can be overwritten")
+	}
+
+	switch strategy {
+	case conflictOverwriteIfNewer:
+		if !in.modTime.After(existing.ModTime()) {
+			return dst, outcomeSkipped, http.StatusOK, nil
+		}
+	case conflictOverwriteIfDifferent:
+		newHash, err := in.hash()
+		if err != nil {
+			return "", "", http.StatusInternalServerError, errors.New("failed to hash file")
+		}
+		oldHash, err := getFileHash(ctx, security.GetRelativePath(basePath, dst), dst)
+		if err != nil {
+			return "", "", http.StatusInternalServerError, errors.New("failed to hash existing file")
+		}
+		if newHash == oldHash {
+			return dst, outcomeSkipped, http.StatusOK, nil
+		}
+	}
+	return dst, outcomeOverwritten, http.StatusOK, nil
+}
+
+// forgetOverwritten drops cached metadata for a file that is about to be replaced
+func forgetOverwritten(ctx context.Context, basePath, fullPath string) {
+	relPath := security.GetRelativePath(basePath, fullPath)
+	if oldHash, err := db.GetFileHash(relPath); err == nil {
+		if err := getImageCache().Invalidate(oldHash); err != nil {
+			slog.WarnContext(ctx, "Failed to invalidate image cache", "path", relPath, "error", err)
+		}
+	}
+	if err := db.DeleteFileHash(relPath); err != nil {
+		slog.WarnContext(ctx, "Failed to drop cached file hash", "path", relPath, "error", err)
+	}
+	if err := db.DeleteFileOwners(relPath); err != nil {
+		slog.ErrorContext(ctx, "Failed to drop file owners", "path", relPath, "error", err)
+	}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"hextech-panel/db"
+	"hextech-panel/inspect"
+	"hextech-panel/security"
+	"hextech-panel/textenc"
+	"hextech-panel/webhook"
+)
+
+// maxTextContentSize caps how much text the editor API will load or save
+const maxTextContentSize = 2 * 1024 * 1024 // 2MB
+
+// contentSaveMu serializes the hash check and write of content saves
+var contentSaveMu sync.Mutex
+
+// TextContent represents the editable content of a text file
+type TextContent struct {
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+	Size     int64  `json:"size"`
+	MIMEType string `json:"mime_type"`
+	SHA256   string `json:"sha256"`
+}
+
+// parseETag strips quotes and weak markers from an ETag-style header value
+func parseETag(v string) string {
+	v = strings.TrimSpace(v)
+	v = strings.TrimPrefix(v, "W/")
+	return strings.Trim(v, `"`)
+}
+
+// GetFileContent handles fetching the text content of a file for editing
+func GetFileContent(w http.ResponseWriter, r *http.Request) {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	requestedPath := r.URL.Query().Get("path")
+	if requestedPath == "" {
+		writeError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+
+	fullPath, err := security.ValidatePathExists(basePath, requestedPath)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if info.IsDir() {
+		writeError(w, http.StatusBadRequest, "Path is a directory")
+		return
+	}
+	if info.Size() > maxTextContentSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "File is too large to edit (max "+formatBytes(maxTextContentSize)+")")
+		return
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+
+	encoding, err := textenc.Detect(data)
+	if err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, "File is not a text file")
+		return
+	}
+
+	text, err := textenc.Decode(data, encoding)
+	if err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, "Failed to decode file: "+err.Error())
+		return
+	}
+
+	hash := security.ComputeSHA256Bytes(data)
+	if err := db.SaveFileHash(requestedPath, hash); err != nil {
+		slog.WarnContext(r.Context(), "Failed to cache file hash", "path", requestedPath, "error", err)
+	}
+
+	w.Header().Set("ETag", `"`+hash+`"`)
+	writeJSON(w, http.StatusOK, TextContent{
+		Path:     requestedPath,
+		Content:  text,
+		Encoding: encoding,
+		Size:     info.Size(),
+		MIMEType: getMIMEType(filepath.Ext(info.Name())),
+		SHA256:   hash,
+	})
+}
+
+// SaveFileContent handles saving edited text content.
+// The If-Match header must carry the SHA256 the edit was based on.
+func SaveFileContent(w http.ResponseWriter, r *http.Request) {
+	basePath, maxSize, blockedExts, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	expectedHash := parseETag(r.Header.Get("If-Match"))
+	if expectedHash == "" {
+		writeError(w, http.StatusPreconditionRequired, "If-Match header with the file's SHA256 is required")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4*maxTextContentSize)
+
+	var req struct {
+		Path     string `json:"path"`
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Encoding == "" {
+		req.Encoding = textenc.UTF8
+	}
+
+	fullPath, err := security.ValidatePathExists(basePath, req.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid path: "+err.Error())
+		return
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if info.IsDir() {
+		writeError(w, http.StatusBadRequest, "Cannot edit a directory")
+		return
+	}
+
+	content, err := textenc.Encode(req.Content, req.Encoding)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to encode content: "+err.Error())
+		return
+	}
+
+	if int64(len(content)) > maxTextContentSize || int64(len(content)) > maxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, "Content is too large")
+		return
+	}
+
+	// Same checks as ReplaceFile
+	filename := filepath.Base(fullPath)
+	if err := security.ValidateExtension(filename, blockedExts); err != nil {
+		writeError(w, http.StatusBadRequest, "File type not allowed")
+		return
+	}
+	if err := security.ValidateMIME(filename, content); err != nil {
+		writeError(w, http.StatusBadRequest, "MIME type mismatch")
+		return
+	}
+	content, sanitized, err := sanitizeUpload(filename, content)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to sanitize SVG: "+err.Error())
+		return
+	}
+	if finding := inspect.Default.Inspect(filename, content); finding != nil {
+		writeRejection(w, http.StatusBadRequest, finding)
+		return
+	}
+
+	owner := getUserEmail(r)
+	ownerPath := security.GetRelativePath(basePath, fullPath)
+	rules, err := getUploadRules()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load upload rules")
+		return
+	}
+	if status, err := rules.check(ownerPath, int64(len(content)), content); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	delta := computeQuotaDelta(fullPath, ownerPath, owner, int64(len(content)))
+	if status, err := checkQuotas(basePath, ownerPath, owner, delta); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	// Scan for malware before the existing file is replaced
+	hash := security.ComputeSHA256Bytes(content)
+	if status, finding := scanUpload(r.Context(), basePath, ownerPath, hash, content, getClientIP(r)); finding != nil {
+		uploadRejections.Inc(finding.Code)
+		writeRejection(w, status, finding)
+		return
+	}
+
+	contentSaveMu.Lock()
+	defer contentSaveMu.Unlock()
+
+	// Compare against the file on disk rather than the cache so outside edits are detected
+	current, err := os.ReadFile(fullPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	currentHash := security.ComputeSHA256Bytes(current)
+	if !strings.EqualFold(currentHash, expectedHash) {
+		w.Header().Set("ETag", `"`+currentHash+`"`)
+		writeJSON(w, http.StatusPreconditionFailed, map[string]string{
+			"error":  "File was modified by someone else",
+			"sha256": currentHash,
+		})
+		return
+	}
+
+	if err := os.WriteFile(fullPath, content, 0644); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to write file")
+		return
+	}
+
+	if err := db.SaveFileHash(req.Path, hash); err != nil {
+		slog.WarnContext(r.Context(), "Failed to cache file hash", "path", req.Path, "error", err)
+	}
+	if owner != "" {
+		if err := db.SetFileOwner(ownerPath, owner, int64(len(content))); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to record file owner", "path", ownerPath, "owner", owner, "error", err)
+		}
+	}
+	activityID := logActivity(r.Context(), "replace", req.Path, getClientIP(r))
+	publishEvent(r, activityID, webhook.EventReplace, ownerPath, "", false)
+	purgeCache(ownerPath)
+
+	response := map[string]interface{}{
+		"message": "File saved successfully",
+		"path":    req.Path,
+		"sha256":  hash,
+	}
+	if len(sanitized) > 0 {
+		response["sanitized"] = sanitized
+	}
+	w.Header().Set("ETag", `"`+hash+`"`)
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hextech-panel/db"
+	"hextech-panel/security"
+	"hextech-panel/webhook"
+)
+
+// maxRenameAttempts bounds the search for a free "name-n" suffix
+const maxRenameAttempts = 1000
+
+// copyStats summarizes a tree copy
+type copyStats struct {
+	Copied      int
+	Skipped     int
+	Overwritten []string // destinations of the files written over
+	Errors      []string
+}
+
+// copyFileContents copies a regular file to a new path, preserving its mode and modification time.
+// The data is cloned with a reflink where the filesystem supports it.
+func copyFileContents(src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+
+	// Fall back to io.Copy, which uses copy_file_range between files on Linux
+	if err = reflink(out, in); err != nil {
+		_, err = io.Copy(out, in)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return 0, err
+	}
+
+	os.Chtimes(dst, info.ModTime(), info.ModTime())
+	return info.Size(), nil
+}
+
+// overwriteFile copies src over an existing file by writing a temporary file and renaming it into place
+func overwriteFile(src, dst string) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".copy-*")
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+
+	n, err := copyFileContents(src, tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	return n, nil
+}
+
+// copyTree copies a file or directory tree to dst. With conflictSkip or conflictOverwrite
+// an existing destination directory is merged into; any other strategy requires dst not to exist.
+// Symlinks are skipped. onFile, if set, is called for every file written.
+func copyTree(src, dst, strategy string, onFile func(srcPath, dstPath string, size int64)) (copyStats, error) {
+	stats := copyStats{Errors: []string{}}
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		existing, statErr := os.Lstat(target)
+		exists := statErr == nil
+
+		if exists && existing.Mode()&os.ModeSymlink != 0 {
+			stats.Errors = append(stats.Errors, filepath.ToSlash(rel)+": destination is a symlink")
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			if !exists {
+				return os.Mkdir(target, 0755)
+			}
+			if existing.IsDir() && (strategy == conflictSkip || strategy == conflictOverwrite) {
+				return nil
+			}
+			stats.Errors = append(stats.Errors, filepath.ToSlash(rel)+": a file with this name already exists")
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		var n int64
+		switch {
+		case !exists:
+			n, err = copyFileContents(path, target)
+		case existing.IsDir():
+			stats.Errors = append(stats.Errors, filepath.ToSlash(rel)+": a directory with this name already exists")
+			return nil
+		case strategy == conflictSkip:
+			stats.Skipped++
+			return nil
+		case strategy == conflictOverwrite:
+			n, err = overwriteFile(path, target)
+			if err == nil {
+				stats.Overwritten = append(stats.Overwritten, target)
+			}
+		default:
+			return errors.New(filepath.ToSlash(rel) + ": file already exists")
+		}
+		if err != nil {
+			return err
+		}
+
+		stats.Copied++
+		if onFile != nil {
+			onFile(path, target, n)
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// uniqueName finds a free "name-n.ext" variant of fullPath; the suffix stays within the filename rules
+func uniqueName(fullPath string, isDir bool) (string, error) {
+	dir, name := filepath.Split(fullPath)
+	ext := ""
+	if !isDir {
+		ext = filepath.Ext(name)
+	}
+	stem := strings.TrimSuffix(name, ext)
+
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s-%d%s", stem, i, ext))
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("no free name available")
+}
+
+// discardCopy removes a copy that failed partway, with the ownership and hash records made for it
+func discardCopy(dst, dstRel string) {
+	os.RemoveAll(dst)
+	db.DeleteFileOwners(dstRel)
+	db.DeleteFileHash(dstRel)
+}
+
+// copyBookkeeping returns an onFile callback that carries cached hashes over
+// to copies and records the requester as owner
+func copyBookkeeping(ctx context.Context, basePath, owner string) func(srcPath, dstPath string, size int64) {
+	return func(srcPath, dstPath string, size int64) {
+		srcRel := security.GetRelativePath(basePath, srcPath)
+		dstRel := security.GetRelativePath(basePath, dstPath)
+
+		// An overwritten file's thumbnails no longer match its content
+		if oldHash, err := db.GetFileHash(dstRel); err == nil {
+			if err := getImageCache().Invalidate(oldHash); err != nil {
+				slog.WarnContext(ctx, "Failed to invalidate image cache", "path", dstRel, "error", err)
+			}
+		}
+		if hash, err := db.GetFileHash(srcRel); err == nil {
+			if err := db.SaveFileHash(dstRel, hash); err != nil {
+				slog.WarnContext(ctx, "Failed to cache file hash", "path", dstRel, "error", err)
+			}
+		} else if err := db.DeleteFileHash(dstRel); err != nil {
+			slog.WarnContext(ctx, "Failed to drop cached file hash", "path", dstRel, "error", err)
+		}
+
+		if owner != "" {
+			if err := db.SetFileOwner(dstRel, owner, size); err != nil {
+				slog.ErrorContext(ctx, "Failed to record file owner", "path", dstRel, "owner", owner, "error", err)
+			}
+		}
+	}
+}
+
+// checkMoveQuotas verifies that moving the tree at src from srcRel to dstRel fits within
+// the directory quotas it enters. Its owners, and so their quotas, stay the same.
+func checkMoveQuotas(basePath, src, srcRel, dstRel string) (int, error) {
+	bytes, files, err := directoryUsage(src)
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("failed to read source")
+	}
+	return checkQuotas(basePath, dstRel, "", quotaDelta{dirBytes: bytes, dirFiles: files, from: srcRel})
+}
+
+// checkCopyQuotas verifies a copy of src fits within the quotas covering dstRel
+func checkCopyQuotas(basePath, src, dstRel, owner string) (int, error) {
+	bytes, files, err := directoryUsage(src)
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("failed to read source")
+	}
+	delta := quotaDelta{dirBytes: bytes, dirFiles: files, userBytes: bytes, userFiles: files}
+	return checkQuotas(basePath, dstRel, owner, delta)
+}
+
+// CopyFile handles copying a file or directory tree, or duplicating it in place
+func CopyFile(w http.ResponseWriter, r *http.Request) {
+	basePath, _, blockedExts, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	var req struct {
+		Path        string `json:"path"`
+		Destination string `json:"destination"`
+		NewName     string `json:"new_name"`
+		OnConflict  string `json:"on_conflict"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Conditional overwrites are decided per file and aren't supported for trees
+	strategy, err := parseConflictStrategy(req.OnConflict)
+	if err != nil || strategy == conflictOverwriteIfNewer || strategy == conflictOverwriteIfDifferent {
+		writeError(w, http.StatusBadRequest, "on_conflict must be fail, skip, overwrite or keep_both")
+		return
+	}
+
+	// Validate source
+	srcPath, err := security.ValidatePathExists(basePath, req.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid source path: "+err.Error())
+		return
+	}
+	if security.GetRelativePath(basePath, srcPath) == "/" {
+		writeError(w, http.StatusBadRequest, "Cannot copy the root directory")
+		return
+	}
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "File not found")
+		return
+	}
+
+	// Destination defaults to the source's directory, i.e. a duplicate
+	dstDir := filepath.Dir(srcPath)
+	if req.Destination != "" {
+		dstDir, err = security.ValidatePathExists(basePath, req.Destination)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid destination: "+err.Error())
+			return
+		}
+	}
+	dstInfo, err := os.Stat(dstDir)
+	if err != nil || !dstInfo.IsDir() {
+		writeError(w, http.StatusBadRequest, "Destination is not a directory")
+		return
+	}
+
+	name := filepath.Base(srcPath)
+	changes := []string{}
+	if req.NewName != "" {
+		if name, changes, err = getFilenamePolicy().Normalize(req.NewName); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid new filename: "+err.Error())
+			return
+		}
+		if err := security.ValidateExtension(name, blockedExts); err != nil {
+			writeError(w, http.StatusBadRequest, "File type not allowed: "+err.Error())
+			return
+		}
+	}
+
+	dstPath := filepath.Join(dstDir, name)
+	// Resolve a conflict on the top-level name
+	if existing, err := os.Lstat(dstPath); err == nil {
+		switch {
+		case strategy == conflictKeepBoth:
+			if dstPath, err = uniqueName(dstPath, srcInfo.IsDir()); err != nil {
+				writeError(w, http.StatusConflict, "Could not find a free name")
+				return
+			}
+		case strategy == conflictFail:
+			writeError(w, http.StatusConflict, "A file with this name already exists at the destination")
+			return
+		case dstPath == srcPath:
+			writeError(w, http.StatusConflict, "Source and destination are the same")
+			return
+		case existing.Mode()&os.ModeSymlink != 0 || existing.IsDir() != srcInfo.IsDir():
+			writeError(w, http.StatusConflict, "A file of a different type already exists at the destination")
+			return
+		}
+	}
+
+	if srcInfo.IsDir() && security.IsInsideDir(srcPath, dstPath) {
+		writeError(w, http.StatusBadRequest, "Cannot copy a directory into itself")
+		return
+	}
+
+	// Enforce upload rules on every file at its new path
+	rules, err := getUploadRules()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load upload rules")
+		return
+	}
+	if status, err := rules.checkExisting(basePath, srcPath, dstPath); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	// Enforce storage quotas
+	owner := getUserEmail(r)
+	dstRel := security.GetRelativePath(basePath, dstPath)
+	if status, err := checkCopyQuotas(basePath, srcPath, dstRel, owner); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	_, statErr := os.Lstat(dstPath)
+	created := os.IsNotExist(statErr)
+	stats, err := copyTree(srcPath, dstPath, strategy, copyBookkeeping(r.Context(), basePath, owner))
+	// Files written over are already changed on disk, even when the copy failed later on
+	overwritten := make([]string, 0, len(stats.Overwritten))
+	for _, p := range stats.Overwritten {
+		overwritten = append(overwritten, security.GetRelativePath(basePath, p))
+	}
+	purgeCache(overwritten...)
+	if err != nil {
+		// A partial copy to a new name is removed so it doesn't count against quotas;
+		// merging into an existing directory leaves what was already there
+		if created {
+			discardCopy(dstPath, dstRel)
+		}
+		writeError(w, http.StatusInternalServerError, "Copy failed: "+err.Error())
+		return
+	}
+
+	// Log activity
+	srcRel := security.GetRelativePath(basePath, srcPath)
+	activityID := logActivity(r.Context(), "copy", srcRel+" -> "+dstRel, getClientIP(r))
+	publishEvent(r, activityID, webhook.EventCopy, dstRel, srcRel, srcInfo.IsDir())
+	for _, relPath := range overwritten {
+		publishEvent(r, activityID, webhook.EventReplace, relPath, "", false)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":      "Copied successfully",
+		"path":         srcRel,
+		"new_path":     dstRel,
+		"copied":       stats.Copied,
+		"skipped":      stats.Skipped,
+		"overwritten":  len(stats.Overwritten),
+		"errors":       stats.Errors,
+		"name_changes": changes,
+	})
+}
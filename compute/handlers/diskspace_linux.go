@@ -0,0 +1,24 @@
+//go:build linux
+
+package handlers
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskSpace returns the bytes available to unprivileged users and the total size
+// of the filesystem holding path
+func diskSpace(path string) (free, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), st.Blocks * uint64(st.Bsize), nil
+}
+
+// writable reports whether the process may create files in the directory at path
+func writable(path string) error {
+	return unix.Access(path, unix.W_OK)
+}
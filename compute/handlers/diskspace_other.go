@@ -0,0 +1,15 @@
+//go:build !linux
+
+package handlers
+
+import "errors"
+
+// diskSpace is not supported on this platform; the disk check is skipped
+func diskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
+
+// writable is not supported on this platform; only the base directory's existence is checked
+func writable(path string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"hextech-panel/config"
+	"hextech-panel/db"
+	"hextech-panel/events"
+	"hextech-panel/security"
+)
+
+const (
+	// maxReplayEvents bounds how many missed events are replayed on resume; clients
+	// further behind, or behind the events kept by EVENT_RETENTION, get a reset event
+	// and should reload their listing
+	maxReplayEvents = 1000
+	// eventPingInterval keeps idle streams open through proxies
+	eventPingInterval = 25 * time.Second
+)
+
+// eventBroker fans file events out to connected event streams
+var eventBroker = events.NewBroker()
+
+var (
+	fileWatcher   *events.Watcher
+	fileWatcherMu sync.Mutex
+)
+
+// WatchBaseDirectory publishes changes made to the base directory outside the panel,
+// moving the watch when the base directory setting changes
+func WatchBaseDirectory() {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		slog.Error("Failed to load settings for the file watcher", "error", err)
+		return
+	}
+
+	fileWatcherMu.Lock()
+	defer fileWatcherMu.Unlock()
+	if fileWatcher != nil {
+		if fileWatcher.Root() == filepath.Clean(basePath) {
+			return
+		}
+		fileWatcher.Close()
+		fileWatcher = nil
+	}
+
+	w, err := events.NewWatcher(basePath, publishWatchedEvent)
+	if err != nil {
+		slog.Error("Failed to watch the base directory; changes made outside the panel will not be streamed", "path", basePath, "error", err)
+		return
+	}
+	fileWatcher = w
+}
+
+// stopFileWatcher stops watching the base directory
+func stopFileWatcher() {
+	fileWatcherMu.Lock()
+	defer fileWatcherMu.Unlock()
+	if fileWatcher != nil {
+		fileWatcher.Close()
+		fileWatcher = nil
+	}
+}
+
+// recordEvent stores a file event, dropping those that fall outside EVENT_RETENTION,
+// and returns its sequence number
+func recordEvent(ctx context.Context, e db.FileEvent) int64 {
+	seq, err := db.RecordFileEvent(e)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record file event", "event", e.Event, "path", e.Path, "error", err)
+		return 0
+	}
+	if err := db.PruneFileEvents(seq, config.Current.EventRetention); err != nil {
+		slog.WarnContext(ctx, "Failed to prune file events", "error", err)
+	}
+	return seq
+}
+
+// publishWatchedEvent records a change made outside the panel, which has no activity,
+// and pushes it to live event streams
+func publishWatchedEvent(e db.FileEvent) {
+	e.Seq = recordEvent(context.Background(), e)
+	eventBroker.Publish(e)
+}
+
+// publishEvent records a file change under the activity that logged it, pushes it to
+// live event streams and queues it for webhooks. oldPath is set for events that move
+// content from one path to another.
+func publishEvent(r *http.Request, activityID int64, event, relPath, oldPath string, isDir bool) {
+	e := db.FileEvent{
+		ActivityID: activityID,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Event:      event,
+		Path:       relPath,
+		OldPath:    oldPath,
+		IsDir:      isDir,
+	}
+	if activityID > 0 {
+		e.Seq = recordEvent(r.Context(), e)
+	}
+	eventBroker.Publish(e)
+
+	// The same change seen on disk is not published again
+	fileWatcherMu.Lock()
+	if fileWatcher != nil {
+		fileWatcher.Ignore(e)
+	}
+	fileWatcherMu.Unlock()
+
+	notifyWebhooks(r, event, relPath, oldPath, isDir)
+}
+
+// writeEvent writes a file event in Server-Sent Events format, using its sequence number
+// as the event ID. An event that could not be recorded has no ID, so the client keeps
+// resuming from the one before it.
+func writeEvent(w http.ResponseWriter, e db.FileEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if e.Seq == 0 {
+		_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.Seq, data)
+	return err
+}
+
+// StreamEvents handles the Server-Sent Events stream of file changes. Clients choose
+// directories with one or more "path" parameters (default: everything) and resume
+// after a disconnect with the Last-Event-ID header or a last_event_id parameter, which
+// carry the sequence number of the last event received.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	dirs := []string{}
+	for _, p := range r.URL.Query()["path"] {
+		fullPath, err := security.ValidatePath(basePath, p)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid path: "+err.Error())
+			return
+		}
+		dirs = append(dirs, security.GetRelativePath(basePath, fullPath))
+	}
+	if len(dirs) == 0 {
+		dirs = append(dirs, "/")
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	resume := lastEventID != ""
+	lastID, err := strconv.ParseInt(lastEventID, 10, 64)
+	if resume && (err != nil || lastID < 0) {
+		writeError(w, http.StatusBadRequest, "Invalid last event ID")
+		return
+	}
+
+	// Subscribe before replaying so nothing published in between is lost
+	sub := eventBroker.Subscribe(dirs)
+	defer eventBroker.Unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	lastSeq := lastID
+	if resume {
+		// Events after lastID may already have been pruned
+		oldest, err := db.GetOldestFileEventSeq()
+		var missed []db.FileEvent
+		if err == nil {
+			missed, err = db.GetFileEventsSince(lastID, maxReplayEvents+1)
+		}
+		switch {
+		case err != nil || len(missed) > maxReplayEvents || oldest > lastID+1:
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		default:
+			for _, e := range missed {
+				lastSeq = e.Seq
+				if !sub.Matches(e) {
+					continue
+				}
+				if writeEvent(w, e) != nil {
+					return
+				}
+			}
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	ping := time.NewTicker(eventPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind or shutting down; the client reconnects and resumes
+				return
+			}
+			if e.Seq != 0 && e.Seq <= lastSeq {
+				continue
+			}
+			if writeEvent(w, e) != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
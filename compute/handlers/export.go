@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"hextech-panel/config"
+	"hextech-panel/db"
+	"hextech-panel/export"
+	"hextech-panel/security"
+)
+
+var (
+	exportManager     *export.Manager
+	exportManagerErr  error
+	exportManagerOnce sync.Once
+)
+
+// getExportManager returns the shared export job manager
+func getExportManager() (*export.Manager, error) {
+	exportManagerOnce.Do(func() {
+		exportManager, exportManagerErr = export.NewManager(export.ManagerConfig{
+			Dir:         filepath.Join(config.Current.CacheDir, "exports"),
+			Limits:      exportLimits(),
+			Concurrency: config.Current.ExportConcurrency,
+			TTL:         time.Duration(config.Current.ExportTTLMinutes) * time.Minute,
+		})
+	})
+	return exportManager, exportManagerErr
+}
+
+// exportRequest is the body accepted by the bulk download and export job endpoints
+type exportRequest struct {
+	Paths  []string `json:"paths"`
+	Format string   `json:"format"`
+	// Level is optional so that an omitted level means the format's default rather than store
+	Level     *int `json:"level"`
+	Checksums bool `json:"checksums"`
+}
+
+// options converts the request into validated archive options
+func (req exportRequest) options() (export.Options, error) {
+	opts := export.Options{
+		Format:    req.Format,
+		Level:     export.LevelDefault,
+		Checksums: req.Checksums,
+		CachedHash: func(relPath string) string {
+			hash, _ := db.GetFileHash(relPath)
+			return hash
+		},
+	}
+	if req.Level != nil {
+		opts.Level = *req.Level
+	}
+	return opts, opts.Validate()
+}
+
+// exportLimits returns the configured per-export limits
+func exportLimits() export.Limits {
+	return export.Limits{MaxFiles: config.Current.ExportMaxFiles, MaxBytes: int64(config.Current.ExportMaxSize)}
+}
+
+// validateExportPaths validates requested paths and resolves them to export sources
+func validateExportPaths(basePath string, paths []string) ([]export.Source, int, error) {
+	if len(paths) == 0 {
+		return nil, http.StatusBadRequest, errors.New("No paths provided")
+	}
+
+	sources := make([]export.Source, 0, len(paths))
+	for _, p := range paths {
+		fullPath, err := security.ValidatePathExists(basePath, p)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.New("Invalid path: " + p)
+		}
+		sources = append(sources, export.Source{
+			FullPath: fullPath,
+			RelPath:  security.GetRelativePath(basePath, fullPath),
+		})
+	}
+	return sources, 0, nil
+}
+
+// exportFilename picks the download name for an archive of the given sources
+func exportFilename(sources []export.Source, format string) string {
+	if len(sources) == 1 {
+		return filepath.Base(sources[0].FullPath) + export.Extension(format)
+	}
+	return "download" + export.Extension(format)
+}
+
+// lookupExportJob fetches a job by the id query parameter, hiding jobs owned by other users
+func lookupExportJob(w http.ResponseWriter, r *http.Request) (*export.Manager, export.Job, bool) {
+	manager, err := getExportManager()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Export jobs are unavailable")
+		return nil, export.Job{}, false
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "Job ID is required")
+		return nil, export.Job{}, false
+	}
+
+	job, err := manager.Get(id)
+	if err != nil || (job.Owner != "" && job.Owner != getUserEmail(r)) {
+		writeError(w, http.StatusNotFound, "Export job not found")
+		return nil, export.Job{}, false
+	}
+	return manager, job, true
+}
+
+// CreateExportJob handles queueing an archive export to be built in the background
+func CreateExportJob(w http.ResponseWriter, r *http.Request) {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	manager, err := getExportManager()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Export jobs are unavailable")
+		return
+	}
+	if security.IsInsideDir(basePath, filepath.Join(config.Current.CacheDir, "exports")) {
+		writeError(w, http.StatusInternalServerError, "Cache directory must be outside the base directory")
+		return
+	}
+
+	var req exportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	opts, err := req.options()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sources, status, err := validateExportPaths(basePath, req.Paths)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	job, err := manager.Submit(sources, opts, exportFilename(sources, opts.Format), getUserEmail(r))
+	if err != nil {
+		if errors.Is(err, export.ErrLimitExceeded) {
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to create export job")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// GetExportJob handles reporting the status and progress of an export job
+func GetExportJob(w http.ResponseWriter, r *http.Request) {
+	_, job, ok := lookupExportJob(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// CancelExportJob handles cancelling an export job and deleting its archive
+func CancelExportJob(w http.ResponseWriter, r *http.Request) {
+	manager, job, ok := lookupExportJob(w, r)
+	if !ok {
+		return
+	}
+
+	if err := manager.Cancel(job.ID); err != nil {
+		writeError(w, http.StatusNotFound, "Export job not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Export job cancelled",
+		"id":      job.ID,
+	})
+}
+
+// DownloadExport handles downloading a finished export archive, with Range support
+func DownloadExport(w http.ResponseWriter, r *http.Request) {
+	manager, job, ok := lookupExportJob(w, r)
+	if !ok {
+		return
+	}
+
+	job, archivePath, err := manager.Archive(job.ID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Export job not found")
+		return
+	}
+	if job.Status != export.StatusCompleted {
+		writeError(w, http.StatusConflict, "Export is not ready (status: "+job.Status+")")
+		return
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		writeError(w, http.StatusGone, "Export archive has expired")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", export.ContentType(job.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.Filename))
+	w.Header().Set("ETag", fmt.Sprintf("%q", job.ID))
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, r, job.Filename, *job.CompletedAt, file)
+	exportBytes.Add(float64(cw.n), "job")
+}
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"hextech-panel/archive"
+	"hextech-panel/config"
+	"hextech-panel/db"
+	"hextech-panel/inspect"
+	"hextech-panel/security"
+	"hextech-panel/webhook"
+)
+
+// Extraction entry statuses
+const (
+	extractStatusExtracted = "extracted"
+	extractStatusRejected  = "rejected"
+)
+
+// ExtractEntry reports the outcome for a single archive entry
+type ExtractEntry struct {
+	Name   string `json:"name"`
+	Path   string `json:"path,omitempty"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// Code is the content inspection reason code when the entry was rejected for its content
+	Code   string `json:"code,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	// NameChanges lists how the filename policy altered the entry's path
+	NameChanges []string `json:"name_changes,omitempty"`
+}
+
+// stagedFile is an accepted entry waiting to be moved into place
+type stagedFile struct {
+	report  int // index into the report
+	staged  string
+	dest    string
+	relPath string
+	size    int64
+}
+
+// stagedDir is a directory entry created once the archive has been read
+type stagedDir struct {
+	name string
+	dest string
+}
+
+// validateRelativePath runs every segment of a slash-separated relative path through
+// the filename policy and returns the normalized path and the changes made
+func validateRelativePath(policy security.FilenamePolicy, name string) (string, []string, error) {
+	segments := strings.Split(name, "/")
+	changes := []string{}
+	for i, seg := range segments {
+		normalized, segChanges, err := policy.Normalize(seg)
+		if err != nil {
+			return "", nil, errors.New("invalid name '" + seg + "': " + err.Error())
+		}
+		segments[i] = normalized
+		for _, change := range segChanges {
+			if !slices.Contains(changes, change) {
+				changes = append(changes, change)
+			}
+		}
+	}
+	return strings.Join(segments, "/"), changes, nil
+}
+
+// ensureDir creates fullDir and any missing parents below root, refusing to
+// traverse symlinks or non-directories
+func ensureDir(root, fullDir string) error {
+	return walkDir(root, fullDir, true)
+}
+
+// checkDir reports whether ensureDir could create fullDir, without creating anything
+func checkDir(root, fullDir string) error {
+	return walkDir(root, fullDir, false)
+}
+
+// walkDir checks each component of fullDir below root, creating missing ones when create is set
+func walkDir(root, fullDir string, create bool) error {
+	rel, err := filepath.Rel(root, fullDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return security.ErrOutsideBaseDir
+	}
+	if rel == "." {
+		return nil
+	}
+
+	current := root
+	for _, seg := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, seg)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			if !create {
+				// Nothing further down exists yet
+				return nil
+			}
+			if err := os.Mkdir(current, 0755); err != nil && !os.IsExist(err) {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return security.ErrSymlinkDetected
+		}
+		if !info.IsDir() {
+			return errors.New("path component is not a directory")
+		}
+	}
+	return nil
+}
+
+// moveFile renames src to dst, falling back to copy and delete across filesystems
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// ExtractArchive handles uploading a .zip or .tar.gz and extracting it into a directory.
+// Entries are validated and staged outside the public tree first, so an archive that
+// trips a size or ratio limit leaves nothing behind.
+func ExtractArchive(w http.ResponseWriter, r *http.Request) {
+	basePath, maxSize, blockedExts, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+	policy := getFilenamePolicy()
+	rules, err := getUploadRules()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load upload rules")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to parse form: "+err.Error())
+		return
+	}
+
+	targetDir := r.FormValue("directory")
+	if targetDir == "" {
+		targetDir = "/"
+	}
+	overwrite := r.FormValue("overwrite") == "true"
+
+	targetPath, err := security.ValidatePathExists(basePath, targetDir)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid target directory: "+err.Error())
+		return
+	}
+
+	dirInfo, err := os.Stat(targetPath)
+	if err != nil || !dirInfo.IsDir() {
+		writeError(w, http.StatusBadRequest, "Target is not a directory")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "No file provided")
+		return
+	}
+	defer file.Close()
+
+	format, err := archive.DetectFormat(header.Filename)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Archive must be a .zip or .tar.gz file")
+		return
+	}
+
+	stagingRoot := filepath.Join(config.Current.CacheDir, "extract")
+	if security.IsInsideDir(basePath, stagingRoot) {
+		writeError(w, http.StatusInternalServerError, "Cache directory must be outside the base directory")
+		return
+	}
+	if err := os.MkdirAll(stagingRoot, 0755); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to prepare extraction")
+		return
+	}
+	staging, err := os.MkdirTemp(stagingRoot, "job-*")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to prepare extraction")
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	limits := archive.Limits{
+		MaxEntries:   config.Current.ExtractMaxEntries,
+		MaxTotalSize: int64(config.Current.ExtractMaxSize),
+		MaxRatio:     float64(config.Current.ExtractMaxRatio),
+	}
+
+	report := make([]ExtractEntry, 0)
+	var staged []stagedFile
+	var dirs []stagedDir
+	seen := make(map[string]bool)
+
+	reject := func(name, reason string) {
+		report = append(report, ExtractEntry{Name: name, Status: extractStatusRejected, Reason: reason})
+	}
+
+	walkErr := archive.Walk(file, header.Size, format, limits, func(entry archive.Entry, rd io.Reader, entryErr error) error {
+		if entryErr != nil {
+			reject(entry.Name, entryErr.Error())
+			return nil
+		}
+
+		rel, nameChanges, err := validateRelativePath(policy, entry.Name)
+		if err != nil {
+			reject(entry.Name, err.Error())
+			return nil
+		}
+
+		// Defense in depth against zip-slip: the joined path must stay inside the target
+		dest := filepath.Join(targetPath, filepath.FromSlash(rel))
+		if !security.IsInsideDir(targetPath, dest) || dest == targetPath {
+			reject(entry.Name, archive.ErrUnsafePath.Error())
+			return nil
+		}
+
+		if entry.IsDir {
+			dirs = append(dirs, stagedDir{name: entry.Name, dest: dest})
+			return nil
+		}
+
+		if seen[rel] {
+			reject(entry.Name, "duplicate entry")
+			return nil
+		}
+
+		filename := filepath.Base(dest)
+		if err := security.ValidateExtension(filename, blockedExts); err != nil {
+			reject(entry.Name, "file type not allowed")
+			return nil
+		}
+		if entry.Size > maxSize {
+			reject(entry.Name, security.ErrFileTooLarge.Error())
+			return nil
+		}
+
+		if _, err := os.Lstat(dest); err == nil && !overwrite {
+			reject(entry.Name, "file already exists")
+			return nil
+		}
+
+		// Size and ratio violations surface here and abort the whole extraction
+		content, err := io.ReadAll(rd)
+		if err != nil {
+			return err
+		}
+
+		if err := security.ValidateMIME(filename, content); err != nil {
+			reject(entry.Name, err.Error())
+			return nil
+		}
+		if finding := inspect.Default.Inspect(filename, content); finding != nil {
+			report = append(report, ExtractEntry{Name: entry.Name, Status: extractStatusRejected, Reason: finding.Reason, Code: finding.Code})
+			return nil
+		}
+		if _, err := rules.check(security.GetRelativePath(basePath, dest), int64(len(content)), content); err != nil {
+			reject(entry.Name, err.Error())
+			return nil
+		}
+		hash := security.ComputeSHA256Bytes(content)
+		if _, finding := scanUpload(r.Context(), basePath, security.GetRelativePath(basePath, dest), hash, content, getClientIP(r)); finding != nil {
+			report = append(report, ExtractEntry{Name: entry.Name, Status: extractStatusRejected, Reason: finding.Reason, Code: finding.Code})
+			return nil
+		}
+
+		stagedPath := filepath.Join(staging, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(stagedPath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(stagedPath, content, 0644); err != nil {
+			return err
+		}
+
+		seen[rel] = true
+		report = append(report, ExtractEntry{
+			Name:        entry.Name,
+			Path:        security.GetRelativePath(basePath, dest),
+			Status:      extractStatusExtracted,
+			Size:        int64(len(content)),
+			SHA256:      hash,
+			NameChanges: nameChanges,
+		})
+		staged = append(staged, stagedFile{
+			report:  len(report) - 1,
+			staged:  stagedPath,
+			dest:    dest,
+			relPath: security.GetRelativePath(basePath, dest),
+			size:    int64(len(content)),
+		})
+		return nil
+	})
+	if walkErr != nil {
+		status := http.StatusBadRequest
+		if errors.Is(walkErr, archive.ErrTooLarge) || errors.Is(walkErr, archive.ErrTooManyEntries) ||
+			errors.Is(walkErr, archive.ErrSuspiciousRatio) {
+			status = http.StatusUnprocessableEntity
+		}
+		writeError(w, status, "Extraction aborted: "+walkErr.Error())
+		return
+	}
+
+	// Commit staged files into the public tree
+	owner := getUserEmail(r)
+	for _, dir := range dirs {
+		if err := ensureDir(targetPath, dir.dest); err != nil {
+			reject(dir.name, err.Error())
+		}
+	}
+	var written, overwritten []string
+	for _, sf := range staged {
+		entry := &report[sf.report]
+		fail := func(reason string) {
+			entry.Status = extractStatusRejected
+			entry.Reason = reason
+			entry.Path, entry.Size, entry.SHA256 = "", 0, ""
+		}
+
+		delta := computeQuotaDelta(sf.dest, sf.relPath, owner, sf.size)
+		if _, err := checkQuotas(basePath, sf.relPath, owner, delta); err != nil {
+			fail(err.Error())
+			continue
+		}
+		if err := ensureDir(targetPath, filepath.Dir(sf.dest)); err != nil {
+			fail(err.Error())
+			continue
+		}
+		info, err := os.Lstat(sf.dest)
+		if err == nil && (info.IsDir() || info.Mode()&os.ModeSymlink != 0) {
+			fail("destination is not a regular file")
+			continue
+		}
+		replaced := err == nil
+		if err := moveFile(sf.staged, sf.dest); err != nil {
+			fail("failed to write file")
+			continue
+		}
+		if replaced {
+			// Drop the previous content's thumbnails and its owner's share of the quota
+			forgetOverwritten(r.Context(), basePath, sf.dest)
+		}
+
+		if err := db.SaveFileHash(sf.relPath, entry.SHA256); err != nil {
+			slog.WarnContext(r.Context(), "Failed to cache file hash", "path", sf.relPath, "error", err)
+		}
+		if owner != "" {
+			if err := db.SetFileOwner(sf.relPath, owner, sf.size); err != nil {
+				slog.ErrorContext(r.Context(), "Failed to record file owner", "path", sf.relPath, "owner", owner, "error", err)
+			}
+		}
+		written = append(written, sf.relPath)
+		if replaced {
+			overwritten = append(overwritten, sf.relPath)
+		}
+	}
+
+	targetRel := security.GetRelativePath(basePath, targetPath)
+	activityID := logActivity(r.Context(), "extract", filepath.Base(header.Filename)+" -> "+targetRel, getClientIP(r))
+	for _, relPath := range written {
+		publishEvent(r, activityID, webhook.EventUpload, relPath, "", false)
+	}
+	purgeCache(overwritten...)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":   "Archive extracted",
+		"archive":   header.Filename,
+		"directory": targetRel,
+		"extracted": len(written),
+		"rejected":  len(report) - len(written),
+		"entries":   report,
+	})
+}
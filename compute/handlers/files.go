@@ -1,11 +1,16 @@
 package handlers
 
 import (
-	"archive/zip"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -15,7 +20,11 @@ import (
 
 	"hextech-panel/config"
 	"hextech-panel/db"
+	"hextech-panel/export"
+	"hextech-panel/inspect"
+	"hextech-panel/middleware"
 	"hextech-panel/security"
+	"hextech-panel/webhook"
 )
 
 // FileInfo represents a file or directory
@@ -51,26 +60,63 @@ type ListResponse struct {
 func getSettings() (basePath string, maxUploadSize int64, blockedExts []string, publicHost string, err error) {
 	basePath, err = db.GetSetting("base_directory")
 	if err != nil || basePath == "" {
-		basePath = config.CDNPath // Use env var default
+		basePath = config.Current.CDNPath // Use env var default
 	}
 
-	maxSizeStr, _ := db.GetSetting("max_upload_size")
-	maxUploadSize, _ = strconv.ParseInt(maxSizeStr, 10, 64)
+	if maxSizeStr := optionalSetting("max_upload_size"); maxSizeStr != "" {
+		size, perr := strconv.ParseInt(maxSizeStr, 10, 64)
+		if perr != nil {
+			slog.Warn("Invalid max_upload_size setting, using the default", "value", maxSizeStr)
+		}
+		maxUploadSize = size
+	}
 	if maxUploadSize == 0 {
-		maxUploadSize = 100 * 1024 * 1024 // 100MB default
+		maxUploadSize = int64(config.Current.MaxUploadSize) // Use env var default
 	}
 
-	blockedStr, _ := db.GetSetting("blocked_extensions")
-	blockedExts = security.ParseBlockedExtensions(blockedStr)
+	blockedExts = blockedExtensions(optionalSetting("blocked_extensions"))
 
-	publicHost, _ = db.GetSetting("public_hostname")
+	publicHost = optionalSetting("public_hostname")
 	if publicHost == "" {
-		publicHost = config.PublicHostname // Use env var default
+		publicHost = config.Current.PublicHostname // Use env var default
 	}
 
 	return
 }
 
+// optionalSetting reads a setting that falls back to a default, logging read failures
+// other than the setting being absent
+func optionalSetting(key string) string {
+	value, err := db.GetSetting(key)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("Failed to load setting", "key", key, "error", err)
+	}
+	return value
+}
+
+// logActivity records an activity and returns its ID. A failure is logged rather than
+// failing a request whose file operation already succeeded.
+func logActivity(ctx context.Context, action, filePath, clientIP string) int64 {
+	id, err := db.LogActivity(action, filePath, clientIP)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to log activity", "action", action, "path", filePath, "error", err)
+	}
+	return id
+}
+
+// blockedExtensions parses the blocked_extensions setting, falling back to BLOCKED_EXTENSIONS
+func blockedExtensions(setting string) []string {
+	if setting == "" {
+		setting = config.Current.BlockedExtensions // Use env var default
+	}
+	return security.ParseBlockedExtensions(setting)
+}
+
+// publicURL builds the CDN URL a file is served from, escaping the path
+func publicURL(publicHost, relPath string) string {
+	return (&url.URL{Scheme: "https", Host: publicHost, Path: "/" + strings.TrimPrefix(relPath, "/")}).String()
+}
+
 // getClientIP extracts client IP from request
 func getClientIP(r *http.Request) string {
 	// Check Cloudflare header first
@@ -85,6 +131,11 @@ func getClientIP(r *http.Request) string {
 	return strings.Split(r.RemoteAddr, ":")[0]
 }
 
+// getUserEmail returns the authenticated user's email, normalized for comparison
+func getUserEmail(r *http.Request) string {
+	return strings.ToLower(middleware.GetAuthenticatedEmail(r))
+}
+
 // writeJSON writes a JSON response
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -97,6 +148,23 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
 
+// sanitizeUpload cleans an uploaded SVG when sanitization is enabled, returning
+// the content to store and what was removed
+func sanitizeUpload(filename string, content []byte) ([]byte, []inspect.Removal, error) {
+	if !config.Current.SanitizeSVG || !strings.EqualFold(filepath.Ext(filename), ".svg") {
+		return content, nil, nil
+	}
+	return inspect.SanitizeSVG(content)
+}
+
+// writeRejection responds with a content inspection or scan finding and its reason code
+func writeRejection(w http.ResponseWriter, status int, f *inspect.Finding) {
+	writeJSON(w, status, map[string]string{
+		"error": "Content rejected: " + f.Reason,
+		"code":  f.Code,
+	})
+}
+
 // ListFiles handles listing directory contents
 func ListFiles(w http.ResponseWriter, r *http.Request) {
 	basePath, _, _, _, err := getSettings()
@@ -129,6 +197,7 @@ func ListFiles(w http.ResponseWriter, r *http.Request) {
 
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to read directory", "path", requestedPath, "error", err)
 		writeError(w, http.StatusInternalServerError, "Failed to read directory")
 		return
 	}
@@ -220,6 +289,30 @@ func getMIMEType(ext string) string {
 	return "application/octet-stream"
 }
 
+// getFileHash returns the cached SHA256 of a file, computing and caching it if missing
+func getFileHash(ctx context.Context, relPath, fullPath string) (string, error) {
+	if hash, err := db.GetFileHash(relPath); err == nil {
+		return hash, nil
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash, err := security.ComputeSHA256(f)
+	if err != nil {
+		return "", err
+	}
+
+	// Cache the hash
+	if err := db.SaveFileHash(relPath, hash); err != nil {
+		slog.WarnContext(ctx, "Failed to cache file hash", "path", relPath, "error", err)
+	}
+	return hash, nil
+}
+
 // GetMetadata handles fetching file metadata
 func GetMetadata(w http.ResponseWriter, r *http.Request) {
 	basePath, _, _, publicHost, err := getSettings()
@@ -252,32 +345,15 @@ func GetMetadata(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Compute or retrieve SHA256
-	hash, err := db.GetFileHash(requestedPath)
+	hash, err := getFileHash(r.Context(), requestedPath, fullPath)
 	if err != nil {
-		// Compute hash
-		f, err := os.Open(fullPath)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to read file")
-			return
-		}
-		defer f.Close()
-
-		hash, err = security.ComputeSHA256(f)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to compute hash")
-			return
-		}
-
-		// Cache the hash
-		db.SaveFileHash(requestedPath, hash)
+		slog.ErrorContext(r.Context(), "Failed to compute file hash", "path", requestedPath, "error", err)
+		writeError(w, http.StatusInternalServerError, "Failed to compute hash")
+		return
 	}
 
 	ext := filepath.Ext(info.Name())
 
-	// Build public URL
-	publicPath := strings.TrimPrefix(requestedPath, "/")
-	publicURL := "https://" + publicHost + "/" + publicPath
-
 	metadata := FileMetadata{
 		Name:      info.Name(),
 		Path:      requestedPath,
@@ -287,13 +363,221 @@ func GetMetadata(w http.ResponseWriter, r *http.Request) {
 		SHA256:    hash,
 		Created:   info.ModTime(), // Go doesn't have creation time on all platforms
 		Modified:  info.ModTime(),
-		PublicURL: publicURL,
+		PublicURL: publicURL(publicHost, requestedPath),
 	}
 
 	writeJSON(w, http.StatusOK, metadata)
 }
 
-// UploadFile handles file uploads
+// UploadResult reports the outcome for a single uploaded file
+type UploadResult struct {
+	Name   string `json:"name"`
+	Path   string `json:"path,omitempty"`
+	Result string `json:"result"`
+	SHA256 string `json:"sha256,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Code is the content inspection reason code when the file was rejected for its content
+	Code string `json:"code,omitempty"`
+	// Sanitized lists what was stripped from an SVG before it was stored
+	Sanitized []inspect.Removal `json:"sanitized,omitempty"`
+	// NameChanges lists how the filename policy altered the requested name
+	NameChanges []string `json:"name_changes,omitempty"`
+
+	// activityID is the activity log entry recording a stored upload
+	activityID int64
+}
+
+// nameChanges returns changes as a non-nil list, so responses always carry an array
+func nameChanges(changes []string) []string {
+	if changes == nil {
+		return []string{}
+	}
+	return changes
+}
+
+// uploadResultFailed marks a file that was not written
+const uploadResultFailed = "failed"
+
+// uploadRequest holds what is shared by every file in an upload request
+type uploadRequest struct {
+	ctx         context.Context
+	basePath    string
+	targetPath  string
+	maxSize     int64
+	blockedExts []string
+	policy      security.FilenamePolicy
+	rules       uploadRules
+	strategy    string
+	owner       string
+	clientIP    string
+}
+
+// saveUpload validates and writes a single uploaded file, creating intermediate
+// directories from its relative path. Returns the HTTP status describing a failure.
+func (u *uploadRequest) saveUpload(header *multipart.FileHeader, relPath string, lastModified time.Time) (UploadResult, int) {
+	start := time.Now()
+	result := UploadResult{Name: header.Filename, Result: uploadResultFailed}
+	fail := func(status int, msg string) (UploadResult, int) {
+		result.Error = msg
+		return result, status
+	}
+	reject := func(reason string, status int, msg string) (UploadResult, int) {
+		uploadRejections.Inc(reason)
+		return fail(status, msg)
+	}
+
+	if relPath == "" {
+		relPath = header.Filename
+	} else {
+		result.Name = relPath
+	}
+
+	// Validate every path segment, the last one being the filename
+	normalized, nameChanges, err := validateRelativePath(u.policy, strings.Trim(strings.ReplaceAll(relPath, "\\", "/"), "/"))
+	if err != nil {
+		return reject(rejectInvalidFilename, http.StatusBadRequest, "Invalid filename: "+err.Error())
+	}
+	result.NameChanges = nameChanges
+	segments := strings.Split(normalized, "/")
+	filename := segments[len(segments)-1]
+	dirPath := filepath.Join(append([]string{u.targetPath}, segments[:len(segments)-1]...)...)
+
+	// Check extension
+	if err := security.ValidateExtension(filename, u.blockedExts); err != nil {
+		return reject(rejectBlockedExtension, http.StatusBadRequest, "File type not allowed: "+err.Error())
+	}
+
+	if header.Size > u.maxSize {
+		return reject(rejectTooLarge, http.StatusRequestEntityTooLarge, security.ErrFileTooLarge.Error())
+	}
+
+	// Read file content
+	file, err := header.Open()
+	if err != nil {
+		slog.ErrorContext(u.ctx, "Failed to open uploaded file", "name", header.Filename, "error", err)
+		return fail(http.StatusInternalServerError, "Failed to read file")
+	}
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		slog.ErrorContext(u.ctx, "Failed to read uploaded file", "name", header.Filename, "error", err)
+		return fail(http.StatusInternalServerError, "Failed to read file")
+	}
+
+	// Validate MIME type
+	if err := security.ValidateMIME(filename, content); err != nil {
+		return reject(rejectMIMEMismatch, http.StatusBadRequest, "MIME type mismatch: "+err.Error())
+	}
+
+	// Strip active content from SVGs before they are inspected and stored
+	if content, result.Sanitized, err = sanitizeUpload(filename, content); err != nil {
+		return fail(http.StatusBadRequest, "Failed to sanitize SVG: "+err.Error())
+	}
+
+	// Inspect content for executables and embedded scripts
+	if finding := inspect.Default.Inspect(filename, content); finding != nil {
+		result.Code = finding.Code
+		return reject(finding.Code, http.StatusBadRequest, "Content rejected: "+finding.Reason)
+	}
+
+	// Compute hash
+	hash := security.ComputeSHA256Bytes(content)
+
+	// Intermediate directories are only created once every check has passed, so make
+	// sure now that they can be, refusing to traverse symlinks
+	if err := checkDir(u.targetPath, dirPath); err != nil {
+		return fail(http.StatusBadRequest, "Failed to create directory: "+err.Error())
+	}
+
+	// Resolve conflicts with an existing file
+	filePath, outcome, status, err := resolveConflict(u.ctx, u.basePath, filepath.Join(dirPath, filename), u.strategy, incoming{
+		modTime: lastModified,
+		hash:    func() (string, error) { return hash, nil },
+	})
+	if err != nil {
+		return fail(status, err.Error())
+	}
+	relativePath := security.GetRelativePath(u.basePath, filePath)
+	result.Path = relativePath
+	result.Result = outcome
+
+	if outcome == outcomeSkipped {
+		return result, http.StatusOK
+	}
+
+	// Enforce the upload rules of the target directory
+	if status, err := u.rules.check(relativePath, int64(len(content)), content); err != nil {
+		result.Path, result.Result = "", uploadResultFailed
+		return reject(rejectUploadRule, status, err.Error())
+	}
+
+	// Enforce storage quotas
+	delta := computeQuotaDelta(filePath, relativePath, u.owner, int64(len(content)))
+	if status, err := checkQuotas(u.basePath, relativePath, u.owner, delta); err != nil {
+		result.Path, result.Result = "", uploadResultFailed
+		if status != http.StatusInternalServerError {
+			uploadRejections.Inc(rejectQuota)
+		}
+		return fail(status, err.Error())
+	}
+
+	// Scan for malware before anything on disk changes
+	if status, finding := scanUpload(u.ctx, u.basePath, relativePath, hash, content, u.clientIP); finding != nil {
+		result.Path, result.Result, result.Code = "", uploadResultFailed, finding.Code
+		return reject(finding.Code, status, "Content rejected: "+finding.Reason)
+	}
+
+	// Create intermediate directories
+	if err := ensureDir(u.targetPath, dirPath); err != nil {
+		result.Path, result.Result = "", uploadResultFailed
+		return fail(http.StatusBadRequest, "Failed to create directory: "+err.Error())
+	}
+
+	if outcome == outcomeOverwritten {
+		forgetOverwritten(u.ctx, u.basePath, filePath)
+	}
+
+	// Write file
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		slog.ErrorContext(u.ctx, "Failed to write uploaded file", "path", relativePath, "error", err)
+		result.Path, result.Result = "", uploadResultFailed
+		return fail(http.StatusInternalServerError, "Failed to write file")
+	}
+
+	// Cache hash
+	if err := db.SaveFileHash(relativePath, hash); err != nil {
+		slog.WarnContext(u.ctx, "Failed to cache file hash", "path", relativePath, "error", err)
+	}
+
+	// Record ownership for per-user quotas
+	if u.owner != "" {
+		if err := db.SetFileOwner(relativePath, u.owner, int64(len(content))); err != nil {
+			slog.ErrorContext(u.ctx, "Failed to record file owner", "path", relativePath, "owner", u.owner, "error", err)
+		}
+	}
+
+	// Log activity
+	result.activityID = logActivity(u.ctx, "upload", relativePath, u.clientIP)
+
+	uploadBytes.Add(float64(len(content)), "upload")
+	uploadDuration.ObserveSince(start, "upload")
+
+	result.SHA256 = hash
+	return result, http.StatusCreated
+}
+
+// uploadRequestLimit returns the largest request body accepted by UploadFile: room for
+// the maximum number of files at maxSize each, capped at MAX_UPLOAD_REQUEST_SIZE
+func uploadRequestLimit(maxSize int64) int64 {
+	limit := int64(config.Current.MaxUploadRequestSize)
+	if files := int64(config.Current.MaxUploadFiles); maxSize <= limit/files {
+		limit = maxSize * files
+	}
+	return limit
+}
+
+// UploadFile handles file uploads. A request may carry several "file" parts, each
+// with an optional "relative_path" (in the same order) for folder uploads.
 func UploadFile(w http.ResponseWriter, r *http.Request) {
 	basePath, maxSize, blockedExts, _, err := getSettings()
 	if err != nil {
@@ -301,20 +585,30 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Limit request size
-	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	// Limit request size; each file is also checked against the per-file limit
+	r.Body = http.MaxBytesReader(w, r.Body, uploadRequestLimit(maxSize))
 
 	// Parse multipart form
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		writeError(w, http.StatusBadRequest, "Failed to parse form: "+err.Error())
 		return
 	}
+	defer r.MultipartForm.RemoveAll()
 
 	targetDir := r.FormValue("directory")
 	if targetDir == "" {
 		targetDir = "/"
 	}
-	overwrite := r.FormValue("overwrite") == "true"
+
+	// overwrite=true predates on_conflict and is kept for older clients
+	strategy, err := parseConflictStrategy(r.FormValue("on_conflict"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.FormValue("on_conflict") == "" && r.FormValue("overwrite") == "true" {
+		strategy = conflictOverwrite
+	}
 
 	// Validate target directory
 	targetPath, err := security.ValidatePathExists(basePath, targetDir)
@@ -329,69 +623,166 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, header, err := r.FormFile("file")
-	if err != nil {
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
 		writeError(w, http.StatusBadRequest, "No file provided")
 		return
 	}
-	defer file.Close()
-
-	// Validate filename
-	filename, err := security.ValidateFilename(header.Filename)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid filename: "+err.Error())
+	if len(files) > config.Current.MaxUploadFiles {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("At most %d files may be uploaded at once", config.Current.MaxUploadFiles))
 		return
 	}
 
-	// Check extension
-	if err := security.ValidateExtension(filename, blockedExts); err != nil {
-		writeError(w, http.StatusBadRequest, "File type not allowed: "+err.Error())
+	relPaths := r.MultipartForm.Value["relative_path"]
+	if len(relPaths) > 0 && len(relPaths) != len(files) {
+		writeError(w, http.StatusBadRequest, "relative_path must be given once per file")
 		return
 	}
 
-	// Read file content
-	content, err := io.ReadAll(file)
+	// last_modified (milliseconds since the epoch, as File.lastModified in browsers)
+	// is compared for overwrite_if_newer; without it an upload counts as newer
+	lastModified := r.MultipartForm.Value["last_modified"]
+	modTime := func(i int) time.Time {
+		if i < len(lastModified) {
+			if ms, err := strconv.ParseInt(lastModified[i], 10, 64); err == nil {
+				return time.UnixMilli(ms)
+			}
+		}
+		return time.Now()
+	}
+
+	rules, err := getUploadRules()
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to read file")
+		writeError(w, http.StatusInternalServerError, "Failed to load upload rules")
 		return
 	}
 
-	// Validate MIME type
-	if err := security.ValidateMIME(filename, content); err != nil {
-		writeError(w, http.StatusBadRequest, "MIME type mismatch: "+err.Error())
+	u := &uploadRequest{
+		ctx:         r.Context(),
+		basePath:    basePath,
+		targetPath:  targetPath,
+		maxSize:     maxSize,
+		blockedExts: blockedExts,
+		policy:      getFilenamePolicy(),
+		rules:       rules,
+		strategy:    strategy,
+		owner:       getUserEmail(r),
+		clientIP:    getClientIP(r),
+	}
+
+	// A single plain file keeps the original response format
+	if len(files) == 1 && len(relPaths) == 0 {
+		result, status := u.saveUpload(files[0], "", modTime(0))
+		if result.Result == outcomeCreated || result.Result == outcomeOverwritten {
+			publishEvent(r, result.activityID, webhook.EventUpload, result.Path, "", false)
+		}
+		if result.Result == outcomeOverwritten {
+			purgeCache(result.Path)
+		}
+		switch {
+		case result.Code != "":
+			writeJSON(w, status, map[string]string{"error": result.Error, "code": result.Code})
+		case result.Result == uploadResultFailed:
+			writeError(w, status, result.Error)
+		case result.Result == outcomeSkipped:
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"message":       "File already exists, upload skipped",
+				"path":          result.Path,
+				"result":        result.Result,
+				"original_name": result.Name,
+				"name_changes":  nameChanges(result.NameChanges),
+			})
+		default:
+			response := map[string]interface{}{
+				"message":       "File uploaded successfully",
+				"path":          result.Path,
+				"sha256":        result.SHA256,
+				"result":        result.Result,
+				"original_name": result.Name,
+				"name_changes":  nameChanges(result.NameChanges),
+			}
+			if len(result.Sanitized) > 0 {
+				response["sanitized"] = result.Sanitized
+			}
+			writeJSON(w, http.StatusCreated, response)
+		}
 		return
 	}
 
-	// Compute hash
-	hash := security.ComputeSHA256Bytes(content)
+	results := make([]UploadResult, len(files))
+	failed := 0
+	for i, header := range files {
+		relPath := ""
+		if len(relPaths) > 0 {
+			relPath = relPaths[i]
+		}
+		results[i], _ = u.saveUpload(header, relPath, modTime(i))
+		switch results[i].Result {
+		case uploadResultFailed:
+			failed++
+		case outcomeCreated:
+			publishEvent(r, results[i].activityID, webhook.EventUpload, results[i].Path, "", false)
+		case outcomeOverwritten:
+			publishEvent(r, results[i].activityID, webhook.EventUpload, results[i].Path, "", false)
+			purgeCache(results[i].Path)
+		}
+	}
+
+	message := "Files uploaded successfully"
+	if failed > 0 {
+		message = fmt.Sprintf("%d of %d files failed to upload", failed, len(files))
+	}
 
-	// Full file path
-	filePath := filepath.Join(targetPath, filename)
-	relativePath := filepath.Join(targetDir, filename)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  message,
+		"uploaded": len(files) - failed,
+		"failed":   failed,
+		"results":  results,
+	})
+}
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); err == nil && !overwrite {
-		writeError(w, http.StatusConflict, "File already exists")
-		return
+// relocate renames src to dst under a conflict strategy, carrying cached metadata along.
+// The result must satisfy the upload rules at its final path.
+// A skipped relocation leaves the file at src, which is returned as the final path.
+func relocate(ctx context.Context, basePath, src, dst, strategy string, rules uploadRules) (finalPath, outcome string, status int, err error) {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return "", "", http.StatusNotFound, errors.New("file not found")
 	}
+	srcRel := security.GetRelativePath(basePath, src)
 
-	// Write file
-	if err := os.WriteFile(filePath, content, 0644); err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to write file")
-		return
+	finalPath, outcome, status, err = resolveConflict(ctx, basePath, dst, strategy, incoming{
+		isDir:   srcInfo.IsDir(),
+		modTime: srcInfo.ModTime(),
+		hash:    func() (string, error) { return getFileHash(ctx, srcRel, src) },
+	})
+	if err != nil {
+		return "", "", status, err
+	}
+	if outcome == outcomeSkipped || finalPath == src {
+		return src, outcomeSkipped, http.StatusOK, nil
 	}
 
-	// Cache hash
-	db.SaveFileHash(relativePath, hash)
+	if status, err := rules.checkExisting(basePath, src, finalPath); err != nil {
+		return "", "", status, err
+	}
 
-	// Log activity
-	db.LogActivity("upload", relativePath, getClientIP(r))
+	if outcome == outcomeOverwritten {
+		forgetOverwritten(ctx, basePath, finalPath)
+	}
+	if err := os.Rename(src, finalPath); err != nil {
+		return "", "", http.StatusInternalServerError, err
+	}
 
-	writeJSON(w, http.StatusCreated, map[string]string{
-		"message": "File uploaded successfully",
-		"path":    relativePath,
-		"sha256":  hash,
-	})
+	// Update caches
+	newRelPath := security.GetRelativePath(basePath, finalPath)
+	if err := db.DeleteFileHash(srcRel); err != nil {
+		slog.WarnContext(ctx, "Failed to drop cached file hash", "path", srcRel, "error", err)
+	}
+	if err := db.MoveFileOwners(srcRel, newRelPath); err != nil {
+		slog.ErrorContext(ctx, "Failed to move file owners", "from", srcRel, "to", newRelPath, "error", err)
+	}
+	return finalPath, outcome, http.StatusOK, nil
 }
 
 // RenameFile handles file renaming
@@ -403,14 +794,21 @@ func RenameFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Path    string `json:"path"`
-		NewName string `json:"new_name"`
+		Path       string `json:"path"`
+		NewName    string `json:"new_name"`
+		OnConflict string `json:"on_conflict"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
+	strategy, err := parseConflictStrategy(req.OnConflict)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Validate source path
 	srcPath, err := security.ValidatePathExists(basePath, req.Path)
 	if err != nil {
@@ -419,7 +817,7 @@ func RenameFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate new filename
-	newName, err := security.ValidateFilename(req.NewName)
+	newName, changes, err := getFilenamePolicy().Normalize(req.NewName)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid new filename: "+err.Error())
 		return
@@ -434,29 +832,42 @@ func RenameFile(w http.ResponseWriter, r *http.Request) {
 	// Build destination path
 	dstPath := filepath.Join(filepath.Dir(srcPath), newName)
 
-	// Check if destination exists
-	if _, err := os.Stat(dstPath); err == nil {
-		writeError(w, http.StatusConflict, "A file with this name already exists")
+	rules, err := getUploadRules()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load upload rules")
 		return
 	}
 
 	// Rename
-	if err := os.Rename(srcPath, dstPath); err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to rename file")
+	finalPath, outcome, status, err := relocate(r.Context(), basePath, srcPath, dstPath, strategy, rules)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			writeError(w, status, "Failed to rename file")
+			return
+		}
+		writeError(w, status, err.Error())
 		return
 	}
 
-	// Update hash cache
-	newRelPath := security.GetRelativePath(basePath, dstPath)
-	db.DeleteFileHash(req.Path)
-
-	// Log activity
-	db.LogActivity("rename", req.Path+" -> "+newRelPath, getClientIP(r))
-
-	writeJSON(w, http.StatusOK, map[string]string{
-		"message":  "File renamed successfully",
-		"old_path": req.Path,
-		"new_path": newRelPath,
+	oldRelPath := security.GetRelativePath(basePath, srcPath)
+	newRelPath := security.GetRelativePath(basePath, finalPath)
+	message := "File renamed successfully"
+	if outcome == outcomeSkipped {
+		message = "A file with this name already exists, rename skipped"
+	} else {
+		// Log activity
+		activityID := logActivity(r.Context(), "rename", oldRelPath+" -> "+newRelPath, getClientIP(r))
+		publishEvent(r, activityID, webhook.EventRename, newRelPath, oldRelPath, isDirectory(finalPath))
+		purgeMoved(basePath, oldRelPath, newRelPath, finalPath)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":       message,
+		"old_path":      oldRelPath,
+		"new_path":      newRelPath,
+		"result":        outcome,
+		"original_name": req.NewName,
+		"name_changes":  changes,
 	})
 }
 
@@ -471,12 +882,19 @@ func MoveFile(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Path        string `json:"path"`
 		Destination string `json:"destination"`
+		OnConflict  string `json:"on_conflict"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
+	strategy, err := parseConflictStrategy(req.OnConflict)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Validate source
 	srcPath, err := security.ValidatePathExists(basePath, req.Path)
 	if err != nil {
@@ -501,29 +919,40 @@ func MoveFile(w http.ResponseWriter, r *http.Request) {
 	filename := filepath.Base(srcPath)
 	dstPath := filepath.Join(dstDir, filename)
 
-	// Check if destination file exists
-	if _, err := os.Stat(dstPath); err == nil {
-		writeError(w, http.StatusConflict, "File already exists at destination")
+	rules, err := getUploadRules()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load upload rules")
 		return
 	}
 
 	// Move
-	if err := os.Rename(srcPath, dstPath); err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to move file")
+	finalPath, outcome, status, err := relocate(r.Context(), basePath, srcPath, dstPath, strategy, rules)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			writeError(w, status, "Failed to move file")
+			return
+		}
+		writeError(w, status, err.Error())
 		return
 	}
 
-	// Update cache
-	newRelPath := security.GetRelativePath(basePath, dstPath)
-	db.DeleteFileHash(req.Path)
-
-	// Log activity
-	db.LogActivity("move", req.Path+" -> "+newRelPath, getClientIP(r))
+	oldRelPath := security.GetRelativePath(basePath, srcPath)
+	newRelPath := security.GetRelativePath(basePath, finalPath)
+	message := "File moved successfully"
+	if outcome == outcomeSkipped {
+		message = "File already exists at destination, move skipped"
+	} else {
+		// Log activity
+		activityID := logActivity(r.Context(), "move", oldRelPath+" -> "+newRelPath, getClientIP(r))
+		publishEvent(r, activityID, webhook.EventMove, newRelPath, oldRelPath, isDirectory(finalPath))
+		purgeMoved(basePath, oldRelPath, newRelPath, finalPath)
+	}
 
 	writeJSON(w, http.StatusOK, map[string]string{
-		"message":  "File moved successfully",
-		"old_path": req.Path,
+		"message":  message,
+		"old_path": oldRelPath,
 		"new_path": newRelPath,
+		"result":   outcome,
 	})
 }
 
@@ -571,46 +1000,125 @@ func ReplaceFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
+	start := time.Now()
 
 	// Read content
 	content, err := io.ReadAll(file)
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to read replacement file", "path", targetPath, "error", err)
 		writeError(w, http.StatusInternalServerError, "Failed to read file")
 		return
 	}
 
 	// Validate MIME
 	if err := security.ValidateMIME(filepath.Base(fullPath), content); err != nil {
+		uploadRejections.Inc(rejectMIMEMismatch)
 		writeError(w, http.StatusBadRequest, "MIME type mismatch")
 		return
 	}
 
+	// Strip active content from SVGs before they are inspected and stored
+	content, sanitized, err := sanitizeUpload(filepath.Base(fullPath), content)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to sanitize SVG: "+err.Error())
+		return
+	}
+
+	// Inspect content for executables and embedded scripts
+	if finding := inspect.Default.Inspect(filepath.Base(fullPath), content); finding != nil {
+		uploadRejections.Inc(finding.Code)
+		writeRejection(w, http.StatusBadRequest, finding)
+		return
+	}
+
 	// Check extension still valid
 	if err := security.ValidateExtension(filepath.Base(fullPath), blockedExts); err != nil {
+		uploadRejections.Inc(rejectBlockedExtension)
 		writeError(w, http.StatusBadRequest, "File type not allowed")
 		return
 	}
 
+	// Enforce the upload rules of the file's directory
+	rules, err := getUploadRules()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load upload rules")
+		return
+	}
+	if status, err := rules.check(security.GetRelativePath(basePath, fullPath), int64(len(content)), content); err != nil {
+		uploadRejections.Inc(rejectUploadRule)
+		writeError(w, status, err.Error())
+		return
+	}
+
+	// Enforce storage quotas
+	owner := getUserEmail(r)
+	ownerPath := security.GetRelativePath(basePath, fullPath)
+	delta := computeQuotaDelta(fullPath, ownerPath, owner, int64(len(content)))
+	if status, err := checkQuotas(basePath, ownerPath, owner, delta); err != nil {
+		if status != http.StatusInternalServerError {
+			uploadRejections.Inc(rejectQuota)
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
 	// Compute hash
 	hash := security.ComputeSHA256Bytes(content)
+	oldHash, err := db.GetFileHash(targetPath)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.WarnContext(r.Context(), "Failed to load cached file hash", "path", targetPath, "error", err)
+	}
+
+	// Scan for malware before the existing file is replaced
+	if status, finding := scanUpload(r.Context(), basePath, ownerPath, hash, content, getClientIP(r)); finding != nil {
+		uploadRejections.Inc(finding.Code)
+		writeRejection(w, status, finding)
+		return
+	}
 
 	// Write file
 	if err := os.WriteFile(fullPath, content, 0644); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write replacement file", "path", targetPath, "error", err)
 		writeError(w, http.StatusInternalServerError, "Failed to write file")
 		return
 	}
 
 	// Update hash
-	db.SaveFileHash(targetPath, hash)
+	if err := db.SaveFileHash(targetPath, hash); err != nil {
+		slog.WarnContext(r.Context(), "Failed to cache file hash", "path", targetPath, "error", err)
+	}
+
+	// Drop thumbnails and transforms generated from the previous content
+	if oldHash != "" && oldHash != hash {
+		if err := getImageCache().Invalidate(oldHash); err != nil {
+			slog.WarnContext(r.Context(), "Failed to invalidate image cache", "path", targetPath, "error", err)
+		}
+	}
+
+	// Record ownership for per-user quotas
+	if owner != "" {
+		if err := db.SetFileOwner(ownerPath, owner, int64(len(content))); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to record file owner", "path", ownerPath, "owner", owner, "error", err)
+		}
+	}
+
+	uploadBytes.Add(float64(len(content)), "replace")
+	uploadDuration.ObserveSince(start, "replace")
 
 	// Log activity
-	db.LogActivity("replace", targetPath, getClientIP(r))
+	activityID := logActivity(r.Context(), "replace", targetPath, getClientIP(r))
+	publishEvent(r, activityID, webhook.EventReplace, ownerPath, "", false)
+	purgeCache(ownerPath)
 
-	writeJSON(w, http.StatusOK, map[string]string{
+	response := map[string]interface{}{
 		"message": "File replaced successfully",
 		"path":    targetPath,
 		"sha256":  hash,
-	})
+	}
+	if len(sanitized) > 0 {
+		response["sanitized"] = sanitized
+	}
+	writeJSON(w, http.StatusOK, response)
 }
 
 // DeleteFile handles file deletion
@@ -649,24 +1157,36 @@ func DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Remember which URLs to purge before the files are gone
+	stale := cachedFiles(basePath, fullPath)
+
 	// Delete
 	if info.IsDir() {
 		if err := os.RemoveAll(fullPath); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete directory", "path", req.Path, "error", err)
 			writeError(w, http.StatusInternalServerError, "Failed to delete directory")
 			return
 		}
 	} else {
 		if err := os.Remove(fullPath); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete file", "path", req.Path, "error", err)
 			writeError(w, http.StatusInternalServerError, "Failed to delete file")
 			return
 		}
 	}
 
 	// Remove from cache
-	db.DeleteFileHash(req.Path)
+	if err := db.DeleteFileHash(req.Path); err != nil {
+		slog.WarnContext(r.Context(), "Failed to drop cached file hash", "path", req.Path, "error", err)
+	}
+	if err := db.DeleteFileOwners(security.GetRelativePath(basePath, fullPath)); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to drop file owners", "path", req.Path, "error", err)
+	}
 
 	// Log activity
-	db.LogActivity("delete", req.Path, getClientIP(r))
+	activityID := logActivity(r.Context(), "delete", req.Path, getClientIP(r))
+	publishEvent(r, activityID, webhook.EventDelete, security.GetRelativePath(basePath, fullPath), "", info.IsDir())
+	purgeCache(stale...)
 
 	writeJSON(w, http.StatusOK, map[string]string{
 		"message": "File deleted successfully",
@@ -705,9 +1225,9 @@ func CreateDirectory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate directory name
-	dirName, err := security.ValidateFilename(req.Name)
+	dirName, changes, err := getFilenamePolicy().Normalize(req.Name)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid directory name")
+		writeError(w, http.StatusBadRequest, "Invalid directory name: "+err.Error())
 		return
 	}
 
@@ -718,19 +1238,25 @@ func CreateDirectory(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusConflict, "Directory already exists")
 			return
 		}
+		slog.ErrorContext(r.Context(), "Failed to create directory", "path", req.Path, "name", req.Name, "error", err)
 		writeError(w, http.StatusInternalServerError, "Failed to create directory")
 		return
 	}
 
 	relativePath := security.GetRelativePath(basePath, newPath)
-
-	writeJSON(w, http.StatusCreated, map[string]string{
-		"message": "Directory created successfully",
-		"path":    relativePath,
+	activityID := logActivity(r.Context(), "mkdir", relativePath, getClientIP(r))
+	publishEvent(r, activityID, webhook.EventMkdir, relativePath, "", true)
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"message":       "Directory created successfully",
+		"path":          relativePath,
+		"original_name": req.Name,
+		"name_changes":  changes,
 	})
 }
 
-// DownloadZip handles downloading multiple files/folders as a ZIP archive
+// DownloadZip handles downloading multiple files/folders as a ZIP, tar.gz or tar.zst archive.
+// Exports larger than the configured limits must go through an export job instead.
 func DownloadZip(w http.ResponseWriter, r *http.Request) {
 	basePath, _, _, _, err := getSettings()
 	if err != nil {
@@ -738,118 +1264,45 @@ func DownloadZip(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Paths []string `json:"paths"`
-	}
+	var req exportRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if len(req.Paths) == 0 {
-		writeError(w, http.StatusBadRequest, "No paths provided")
-		return
-	}
-
-	// Validate all paths first
-	validatedPaths := make([]string, 0, len(req.Paths))
-	for _, p := range req.Paths {
-		fullPath, err := security.ValidatePathExists(basePath, p)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid path: "+p)
-			return
-		}
-		validatedPaths = append(validatedPaths, fullPath)
-	}
-
-	// Set headers for ZIP download
-	filename := "download.zip"
-	if len(req.Paths) == 1 {
-		filename = filepath.Base(req.Paths[0]) + ".zip"
-	}
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
-
-	// Create ZIP writer
-	zipWriter := zip.NewWriter(w)
-	defer zipWriter.Close()
-
-	// Add each path to the ZIP
-	for i, fullPath := range validatedPaths {
-		relativePath := req.Paths[i]
-		info, err := os.Stat(fullPath)
-		if err != nil {
-			continue
-		}
-
-		if info.IsDir() {
-			// Walk directory and add all files
-			err = filepath.Walk(fullPath, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-
-				// Skip symlinks
-				if info.Mode()&os.ModeSymlink != 0 {
-					return nil
-				}
-
-				// Get relative path within the directory
-				relPath, err := filepath.Rel(filepath.Dir(fullPath), path)
-				if err != nil {
-					return err
-				}
-
-				// Use forward slashes in ZIP
-				zipPath := strings.ReplaceAll(relPath, "\\", "/")
-
-				if info.IsDir() {
-					// Add directory entry
-					_, err = zipWriter.Create(zipPath + "/")
-					return err
-				}
-
-				// Add file
-				return addFileToZip(zipWriter, path, zipPath)
-			})
-			if err != nil {
-				// Log error but continue with other files
-				continue
-			}
-		} else {
-			// Add single file
-			zipPath := strings.ReplaceAll(filepath.Base(relativePath), "\\", "/")
-			addFileToZip(zipWriter, fullPath, zipPath)
-		}
-	}
-}
-
-// addFileToZip adds a single file to a ZIP archive
-func addFileToZip(zipWriter *zip.Writer, filePath, zipPath string) error {
-	file, err := os.Open(filePath)
+	opts, err := req.options()
 	if err != nil {
-		return err
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	defer file.Close()
 
-	info, err := file.Stat()
+	sources, status, err := validateExportPaths(basePath, req.Paths)
 	if err != nil {
-		return err
+		writeError(w, status, err.Error())
+		return
 	}
 
-	header, err := zip.FileInfoHeader(info)
+	// Plan the archive up front so limit violations are reported before streaming starts
+	plan, err := export.Collect(sources, exportLimits())
 	if err != nil {
-		return err
+		if errors.Is(err, export.ErrLimitExceeded) {
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error()+"; use an export job instead")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to prepare archive", "error", err)
+		writeError(w, http.StatusInternalServerError, "Failed to prepare archive")
+		return
 	}
 
-	header.Name = zipPath
-	header.Method = zip.Deflate
+	// Set headers for the archive download
+	w.Header().Set("Content-Type", export.ContentType(opts.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(sources, opts.Format)))
 
-	writer, err := zipWriter.CreateHeader(header)
-	if err != nil {
-		return err
+	// Unreadable files are listed in the archive; once streaming has started,
+	// other errors can only be logged and the response truncated
+	cw := &countingWriter{ResponseWriter: w}
+	if err := export.Write(r.Context(), cw, plan, opts, nil); err != nil {
+		slog.ErrorContext(r.Context(), "Archive download failed", "error", err)
 	}
-
-	_, err = io.Copy(writer, file)
-	return err
+	exportBytes.Add(float64(cw.n), "stream")
 }
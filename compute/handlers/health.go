@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"hextech-panel/config"
+	"hextech-panel/db"
+)
+
+// Version is the release the binary was built from, set at build time with
+// -ldflags "-X hextech-panel/handlers.Version=v1.2.3"
+var Version = "dev"
+
+// startedAt is when the process started, for reporting uptime
+var startedAt = time.Now()
+
+// readyCheckTimeout bounds each readiness check, so a hung dependency fails the check
+// instead of the probe
+const readyCheckTimeout = 5 * time.Second
+
+// Readiness check statuses
+const (
+	checkOK       = "ok"
+	checkFailed   = "failed"
+	checkDegraded = "degraded"
+	checkSkipped  = "skipped"
+)
+
+// CheckResult is the outcome of one readiness check
+type CheckResult struct {
+	Status    string                 `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	LatencyMS float64                `json:"latency_ms"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// readyCheck verifies one dependency
+type readyCheck func(ctx context.Context) CheckResult
+
+// checkError builds the result of a check that did not pass
+func checkError(err error) CheckResult {
+	return CheckResult{Status: checkFailed, Error: err.Error()}
+}
+
+// checkDatabase verifies that SQLite answers queries
+func checkDatabase(ctx context.Context) CheckResult {
+	if err := db.Ping(ctx); err != nil {
+		return checkError(err)
+	}
+	return CheckResult{Status: checkOK}
+}
+
+// checkStorage verifies that the base directory exists and accepts new files
+func checkStorage(ctx context.Context) CheckResult {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		return checkError(errors.New("failed to load settings"))
+	}
+	details := map[string]interface{}{"path": basePath}
+
+	info, err := os.Stat(basePath)
+	if err != nil {
+		return CheckResult{Status: checkFailed, Error: err.Error(), Details: details}
+	}
+	if !info.IsDir() {
+		return CheckResult{Status: checkFailed, Error: "base directory is not a directory", Details: details}
+	}
+
+	// Asking for permission leaves nothing behind, unlike writing a probe file on every hit
+	if err := writable(basePath); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		return CheckResult{Status: checkFailed, Error: "base directory is not writable: " + err.Error(), Details: details}
+	}
+	return CheckResult{Status: checkOK, Details: details}
+}
+
+// checkDiskSpace verifies that the storage volume has at least MIN_FREE_DISK_BYTES free
+func checkDiskSpace(ctx context.Context) CheckResult {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		return checkError(errors.New("failed to load settings"))
+	}
+
+	free, total, err := diskSpace(basePath)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return CheckResult{Status: checkSkipped}
+	}
+	if err != nil {
+		return checkError(err)
+	}
+
+	result := CheckResult{Status: checkOK, Details: map[string]interface{}{
+		"free_bytes":     free,
+		"total_bytes":    total,
+		"min_free_bytes": config.Current.MinFreeDiskBytes,
+	}}
+	if free < uint64(config.Current.MinFreeDiskBytes) {
+		result.Status = checkFailed
+		result.Error = fmt.Sprintf("only %s free, below the %s minimum",
+			formatBytes(int64(free)), formatBytes(int64(config.Current.MinFreeDiskBytes)))
+	}
+	return result
+}
+
+// checkScanner verifies that clamd responds when virus scanning is enabled. With
+// SCAN_FAIL_MODE=open uploads still work without it, so it only degrades readiness.
+func checkScanner(ctx context.Context) CheckResult {
+	client, err := getScanner()
+	if client == nil && err == nil {
+		return CheckResult{Status: checkSkipped}
+	}
+	if err == nil {
+		err = client.Ping(ctx)
+	}
+	if err == nil {
+		return CheckResult{Status: checkOK}
+	}
+
+	result := checkError(err)
+	if config.Current.ScanFailMode == "open" {
+		result.Status = checkDegraded
+	}
+	return result
+}
+
+// readyChecks are run by the readiness probe, keyed by the name they are reported under
+var readyChecks = map[string]readyCheck{
+	"database": checkDatabase,
+	"storage":  checkStorage,
+	"disk":     checkDiskSpace,
+	"scanner":  checkScanner,
+}
+
+// Healthz handles the liveness probe: the process is up and serving requests
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// runReadyChecks runs every readiness check, returning the response status and state
+// along with each check's result
+func runReadyChecks(ctx context.Context) (int, string, map[string]CheckResult) {
+	results := make(map[string]CheckResult, len(readyChecks))
+	ready := true
+	for name, check := range readyChecks {
+		checkCtx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+		start := time.Now()
+		result := check(checkCtx)
+		cancel()
+
+		result.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+		if result.Status == checkFailed {
+			ready = false
+		}
+		results[name] = result
+	}
+
+	if !ready {
+		return http.StatusServiceUnavailable, "not_ready", results
+	}
+	return http.StatusOK, "ready", results
+}
+
+// Readyz handles the readiness probe, running every check and responding 503 if any
+// failed. It is unauthenticated, so the results of the checks are left out.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	status, state, _ := runReadyChecks(r.Context())
+	writeJSON(w, status, map[string]string{"status": state})
+}
+
+// GetReadiness handles the admin readiness report: the readiness probe with the result
+// of every check
+func GetReadiness(w http.ResponseWriter, r *http.Request) {
+	status, state, results := runReadyChecks(r.Context())
+	writeJSON(w, status, map[string]interface{}{
+		"status": state,
+		"checks": results,
+	})
+}
+
+// buildInfo describes the running binary
+func buildInfo() map[string]interface{} {
+	info := map[string]interface{}{
+		"version":    Version,
+		"go_version": runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info["revision"] = s.Value
+			case "vcs.time":
+				info["commit_time"] = s.Value
+			case "vcs.modified":
+				info["modified"] = s.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// GetDiagnostics handles the admin diagnostics report: build, uptime, database size,
+// runtime statistics and the loaded configuration with secrets redacted
+func GetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	database := map[string]interface{}{}
+	if dbPath, size, err := db.Size(); err != nil {
+		database["error"] = err.Error()
+	} else {
+		database["path"] = dbPath
+		database["size_bytes"] = size
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"build":          buildInfo(),
+		"started_at":     startedAt.UTC().Format(time.RFC3339),
+		"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+		"database":       database,
+		"runtime": map[string]interface{}{
+			"goroutines":    runtime.NumGoroutine(),
+			"heap_bytes":    mem.HeapAlloc,
+			"gomaxprocs":    runtime.GOMAXPROCS(0),
+			"event_streams": eventBroker.Subscribers(),
+		},
+		"config": config.Current.Redacted(),
+	})
+}
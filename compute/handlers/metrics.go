@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"hextech-panel/config"
+	"hextech-panel/metrics"
+)
+
+// storageUsageTTL is how long a storage walk is reused, so frequent scrapes of a
+// large tree don't walk it every time
+const storageUsageTTL = time.Minute
+
+var (
+	uploadBytes = metrics.NewCounter("hextech_upload_bytes_total",
+		"Bytes written by uploads and replacements.", "op")
+	uploadDuration = metrics.NewHistogram("hextech_upload_duration_seconds",
+		"Time taken to validate, scan and write an uploaded or replaced file.",
+		metrics.DefBuckets, "op")
+	uploadRejections = metrics.NewCounter("hextech_upload_rejections_total",
+		"Uploads and replacements rejected by validation, by reason.", "reason")
+	exportBytes = metrics.NewCounter("hextech_export_bytes_total",
+		"Archive bytes sent to clients, streamed directly or downloaded from export jobs.", "mode")
+)
+
+// Upload rejection reasons; content and scan rejections use their finding's code
+const (
+	rejectBlockedExtension = "blocked_extension"
+	rejectInvalidFilename  = "invalid_filename"
+	rejectTooLarge         = "too_large"
+	rejectMIMEMismatch     = "mime_mismatch"
+	rejectUploadRule       = "upload_rule"
+	rejectQuota            = "quota_exceeded"
+)
+
+func init() {
+	metrics.NewGaugeFunc("hextech_storage_used_bytes", "Bytes stored under the CDN path.",
+		func() float64 { return float64(getStorageUsage().cdnBytes) })
+	metrics.NewGaugeFunc("hextech_storage_files", "Files stored under the CDN path.",
+		func() float64 { return float64(getStorageUsage().cdnFiles) })
+	metrics.NewGaugeFunc("hextech_cache_used_bytes", "Bytes used by generated thumbnails and transforms.",
+		func() float64 { return float64(getStorageUsage().cacheBytes) })
+	metrics.NewGaugeFunc("hextech_event_stream_subscribers", "Connected live event streams.",
+		func() float64 { return float64(eventBroker.Subscribers()) })
+}
+
+// storageUsage is a snapshot of disk usage
+type storageUsage struct {
+	cdnBytes   int64
+	cdnFiles   int64
+	cacheBytes int64
+	measuredAt time.Time
+}
+
+var (
+	storageUsageMu   sync.Mutex
+	lastStorageUsage storageUsage
+)
+
+// getStorageUsage returns storage usage, walking the CDN and cache directories
+// at most once per storageUsageTTL
+func getStorageUsage() storageUsage {
+	storageUsageMu.Lock()
+	defer storageUsageMu.Unlock()
+
+	if time.Since(lastStorageUsage.measuredAt) < storageUsageTTL {
+		return lastStorageUsage
+	}
+
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		slog.Error("Failed to load settings for storage metrics", "error", err)
+		return lastStorageUsage
+	}
+	usage := storageUsage{measuredAt: time.Now()}
+	if usage.cdnBytes, usage.cdnFiles, err = directoryUsage(basePath); err != nil {
+		slog.Error("Failed to measure storage usage", "error", err)
+	}
+	if usage.cacheBytes, _, err = directoryUsage(config.Current.CacheDir); err != nil {
+		slog.Error("Failed to measure cache usage", "error", err)
+	}
+	lastStorageUsage = usage
+	return usage
+}
+
+// countingWriter counts the bytes written through a response
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (c *countingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
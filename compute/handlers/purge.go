@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"hextech-panel/cloudflare"
+	"hextech-panel/config"
+	"hextech-panel/security"
+)
+
+const (
+	// purgeWindow is how long changes are collected before they are purged together
+	purgeWindow = 2 * time.Second
+	// maxManualPurgeURLs bounds how many URLs a single manual purge may expand to
+	maxManualPurgeURLs = 3000
+)
+
+var (
+	cachePurger     *cloudflare.Purger
+	cachePurgerOnce sync.Once
+)
+
+// getCachePurger returns the shared Cloudflare purger, or nil when purging is not configured
+func getCachePurger() *cloudflare.Purger {
+	cachePurgerOnce.Do(func() {
+		if config.Current.CloudflareZoneID == "" || config.Current.CloudflareAPIToken == "" {
+			return
+		}
+		client := cloudflare.New(config.Current.CloudflareAPIURL, config.Current.CloudflareZoneID, config.Current.CloudflareAPIToken, 30*time.Second)
+		cachePurger = cloudflare.NewPurger(client, purgeWindow)
+	})
+	return cachePurger
+}
+
+// listFiles returns the relative paths of the regular files at or below fullPath
+func listFiles(basePath, fullPath string) []string {
+	var paths []string
+	filepath.Walk(fullPath, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			paths = append(paths, security.GetRelativePath(basePath, p))
+		}
+		return nil
+	})
+	return paths
+}
+
+// cachedFiles lists the files at or below fullPath whose URLs must be purged once
+// it is removed. Returns nil without walking when purging is disabled.
+func cachedFiles(basePath, fullPath string) []string {
+	if getCachePurger() == nil {
+		return nil
+	}
+	return listFiles(basePath, fullPath)
+}
+
+// purgeCache queues the public URLs of changed files for purging
+func purgeCache(relPaths ...string) {
+	purger := getCachePurger()
+	if purger == nil || len(relPaths) == 0 {
+		return
+	}
+	_, _, _, publicHost, err := getSettings()
+	if err != nil {
+		slog.Error("Failed to load settings for cache purge", "error", err)
+		return
+	}
+
+	urls := make([]string, len(relPaths))
+	for i, p := range relPaths {
+		urls[i] = publicURL(publicHost, p)
+	}
+	purger.Queue(urls...)
+}
+
+// purgeMoved purges every file moved from oldRel to newRel, at both locations:
+// the old URLs serve stale content and the new ones may have cached a 404
+func purgeMoved(basePath, oldRel, newRel, newFullPath string) {
+	if getCachePurger() == nil {
+		return
+	}
+	var paths []string
+	for _, p := range listFiles(basePath, newFullPath) {
+		paths = append(paths, p, oldRel+strings.TrimPrefix(p, newRel))
+	}
+	purgeCache(paths...)
+}
+
+// PurgeCache handles purging files or directory trees from Cloudflare's cache on demand.
+// Paths that no longer exist are purged as files.
+func PurgeCache(w http.ResponseWriter, r *http.Request) {
+	purger := getCachePurger()
+	if purger == nil {
+		writeError(w, http.StatusServiceUnavailable, "Cloudflare cache purging is not configured")
+		return
+	}
+
+	basePath, _, _, publicHost, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	var req struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Paths) == 0 {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, p := range req.Paths {
+		fullPath, err := security.ValidatePath(basePath, p)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid path: "+err.Error())
+			return
+		}
+
+		files := []string{security.GetRelativePath(basePath, fullPath)}
+		if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+			files = listFiles(basePath, fullPath)
+		}
+		for _, f := range files {
+			if u := publicURL(publicHost, f); !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+		if len(urls) > maxManualPurgeURLs {
+			writeError(w, http.StatusRequestEntityTooLarge, "Too many files to purge at once")
+			return
+		}
+	}
+
+	purged, err := purger.Purge(r.Context(), urls)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"error":  "Cache purge failed: " + err.Error(),
+			"purged": purged,
+			"total":  len(urls),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Cache purged successfully",
+		"purged":  purged,
+		"urls":    urls,
+	})
+}
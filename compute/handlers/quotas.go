@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"hextech-panel/db"
+	"hextech-panel/middleware"
+	"hextech-panel/security"
+)
+
+// QuotaUsage represents a quota together with its current usage
+type QuotaUsage struct {
+	db.Quota
+	UsedBytes int64 `json:"used_bytes"`
+	UsedFiles int64 `json:"used_files"`
+}
+
+// quotaDelta describes how a write changes usage
+type quotaDelta struct {
+	size      int64 // size of the new content
+	dirBytes  int64
+	dirFiles  int64
+	userBytes int64
+	userFiles int64
+	// from is where moved content comes from; directory quotas that already cover it are unchanged
+	from string
+}
+
+// computeQuotaDelta works out the usage change of writing size bytes to relPath
+func computeQuotaDelta(fullPath, relPath, owner string, size int64) quotaDelta {
+	d := quotaDelta{size: size, dirBytes: size, dirFiles: 1, userBytes: size, userFiles: 1}
+
+	if info, err := os.Stat(fullPath); err == nil && !info.IsDir() {
+		d.dirBytes = size - info.Size()
+		d.dirFiles = 0
+		if prevOwner, err := db.GetFileOwner(relPath); err == nil && prevOwner == owner {
+			d.userBytes = size - info.Size()
+			d.userFiles = 0
+		}
+	}
+	return d
+}
+
+// quotaAppliesTo reports whether a directory quota target covers relPath
+func quotaAppliesTo(target, relPath string) bool {
+	if target == "/" {
+		return true
+	}
+	return relPath == target || strings.HasPrefix(relPath, target+"/")
+}
+
+// directoryUsage walks a directory subtree and totals its files and bytes
+func directoryUsage(fullPath string) (bytes int64, files int64, err error) {
+	err = filepath.Walk(fullPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 || info.IsDir() {
+			return nil
+		}
+		bytes += info.Size()
+		files++
+		return nil
+	})
+	return
+}
+
+// quotaUsage returns the current usage for a quota
+func quotaUsage(basePath string, q db.Quota) (int64, int64, error) {
+	if q.Scope == db.QuotaScopeUser {
+		return db.GetUserUsage(q.Target)
+	}
+	fullPath, err := security.ValidatePath(basePath, q.Target)
+	if err != nil {
+		return 0, 0, err
+	}
+	return directoryUsage(fullPath)
+}
+
+// checkQuotas verifies that a write fits within every applicable quota.
+// Returns the HTTP status to respond with and an error if a quota would be exceeded.
+func checkQuotas(basePath, relPath, owner string, d quotaDelta) (int, error) {
+	quotas, err := db.GetQuotas()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to load quotas")
+	}
+
+	for _, q := range quotas {
+		addBytes, addFiles := d.dirBytes, d.dirFiles
+		switch q.Scope {
+		case db.QuotaScopeDirectory:
+			if !quotaAppliesTo(q.Target, relPath) || (d.from != "" && quotaAppliesTo(q.Target, d.from)) {
+				continue
+			}
+		case db.QuotaScopeUser:
+			if owner == "" || !strings.EqualFold(q.Target, owner) {
+				continue
+			}
+			addBytes, addFiles = d.userBytes, d.userFiles
+		default:
+			continue
+		}
+
+		if q.MaxBytes > 0 && d.size > q.MaxBytes {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf(
+				"file is larger than the %s quota for %s (%s)", q.Scope, q.Target, formatBytes(q.MaxBytes))
+		}
+
+		usedBytes, usedFiles, err := quotaUsage(basePath, q)
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to compute quota usage")
+		}
+
+		if q.MaxBytes > 0 && addBytes > 0 && usedBytes+addBytes > q.MaxBytes {
+			return http.StatusInsufficientStorage, fmt.Errorf(
+				"%s quota for %s exceeded: %s of %s used",
+				q.Scope, q.Target, formatBytes(usedBytes), formatBytes(q.MaxBytes))
+		}
+		if q.MaxFiles > 0 && addFiles > 0 && usedFiles+addFiles > q.MaxFiles {
+			return http.StatusInsufficientStorage, fmt.Errorf(
+				"%s quota for %s exceeded: %d of %d files used",
+				q.Scope, q.Target, usedFiles, q.MaxFiles)
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// formatBytes renders a byte count in human-readable units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// GetQuotas handles listing quotas with their current usage
+func GetQuotas(w http.ResponseWriter, r *http.Request) {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	quotas, err := db.GetQuotas()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch quotas")
+		return
+	}
+
+	result := make([]QuotaUsage, 0, len(quotas))
+	for _, q := range quotas {
+		usedBytes, usedFiles, err := quotaUsage(basePath, q)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to compute usage for "+q.Target)
+			return
+		}
+		result = append(result, QuotaUsage{Quota: q, UsedBytes: usedBytes, UsedFiles: usedFiles})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"quotas":       result,
+		"current_user": middleware.GetAuthenticatedEmail(r),
+	})
+}
+
+// SetQuota handles creating or updating a quota
+func SetQuota(w http.ResponseWriter, r *http.Request) {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	var req struct {
+		Scope    string `json:"scope"`
+		Target   string `json:"target"`
+		MaxBytes int64  `json:"max_bytes"`
+		MaxFiles int64  `json:"max_files"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.MaxBytes < 0 || req.MaxFiles < 0 {
+		writeError(w, http.StatusBadRequest, "Quota limits cannot be negative")
+		return
+	}
+
+	target := strings.TrimSpace(req.Target)
+	switch req.Scope {
+	case db.QuotaScopeDirectory:
+		fullPath, err := security.ValidatePath(basePath, target)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid directory: "+err.Error())
+			return
+		}
+		target = security.GetRelativePath(basePath, fullPath)
+	case db.QuotaScopeUser:
+		if target == "" {
+			writeError(w, http.StatusBadRequest, "User email is required")
+			return
+		}
+		target = strings.ToLower(target)
+	default:
+		writeError(w, http.StatusBadRequest, "Scope must be 'directory' or 'user'")
+		return
+	}
+
+	if err := db.SetQuota(req.Scope, target, req.MaxBytes, req.MaxFiles); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save quota")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Quota saved successfully",
+		"scope":   req.Scope,
+		"target":  target,
+	})
+}
+
+// DeleteQuota handles removing a quota
+func DeleteQuota(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "Invalid quota id")
+		return
+	}
+
+	if err := db.DeleteQuota(id); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete quota")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Quota deleted successfully",
+	})
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package handlers
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request number
+const ficlone = 0x40049409
+
+// reflink clones src's data into dst on filesystems that share extents (Btrfs, XFS)
+func reflink(dst, src *os.File) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd()); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package handlers
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink is not supported on this platform; callers fall back to a regular copy
+func reflink(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"hextech-panel/db"
+	"hextech-panel/security"
+)
+
+// sniffLen is how much content http.DetectContentType looks at
+const sniffLen = 512
+
+// uploadRules holds the upload rules loaded for a request
+type uploadRules []db.UploadRule
+
+// getUploadRules loads every configured upload rule
+func getUploadRules() (uploadRules, error) {
+	rules, err := db.GetUploadRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upload rules")
+	}
+	return rules, nil
+}
+
+// covers reports whether any rule applies to relPath or to something below it
+func (rules uploadRules) covers(relPath string) bool {
+	for _, rule := range rules {
+		// Rules cover subtrees the same way directory quotas do
+		if quotaAppliesTo(rule.Target, relPath) || quotaAppliesTo(relPath, rule.Target) {
+			return true
+		}
+	}
+	return false
+}
+
+// check verifies that a file of the given size written to relPath satisfies every
+// rule covering it. head is the start of the content, used to sniff its MIME type.
+// Returns the HTTP status to respond with and an error describing the violation.
+func (rules uploadRules) check(relPath string, size int64, head []byte) (int, error) {
+	name := path.Base(relPath)
+	for _, rule := range rules {
+		if !quotaAppliesTo(rule.Target, relPath) {
+			continue
+		}
+
+		if rule.MaxSize > 0 && size > rule.MaxSize {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf(
+				"file is larger than the %s limit for %s", formatBytes(rule.MaxSize), rule.Target)
+		}
+		if err := security.ValidateExtensionList(name, rule.Extensions, rule.ExtensionMode == db.RuleModeAllow); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("%s in %s", err, rule.Target)
+		}
+		if len(rule.MIMETypes) > 0 {
+			types := security.DetectMIMETypes(name, head)
+			if err := security.ValidateMIMEList(types, rule.MIMETypes, rule.MIMEMode == db.RuleModeAllow); err != nil {
+				return http.StatusBadRequest, fmt.Errorf("%s in %s (%s)", err, rule.Target, types[len(types)-1])
+			}
+		}
+		if rule.FilenamePattern != "" {
+			re, err := security.CompileFilenamePattern(rule.FilenamePattern)
+			if err != nil {
+				return http.StatusInternalServerError, fmt.Errorf("invalid filename pattern for %s", rule.Target)
+			}
+			if !re.MatchString(name) {
+				return http.StatusBadRequest, fmt.Errorf("%s for %s", security.ErrFilenamePattern, rule.Target)
+			}
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// checkExisting verifies that the file or tree at src may be placed at dst, as by a
+// rename, move or copy. Every file is checked under its new path; symlinks are ignored.
+func (rules uploadRules) checkExisting(basePath, src, dst string) (int, error) {
+	dstRel := security.GetRelativePath(basePath, dst)
+	if !rules.covers(dstRel) {
+		return http.StatusOK, nil
+	}
+
+	status := http.StatusOK
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		head, err := readHead(p)
+		if err != nil {
+			return err
+		}
+		newRel := path.Join(dstRel, filepath.ToSlash(rel))
+
+		// MIME rules trust a type given by the extension only when it agrees with the
+		// content, as checked on upload; a new name could otherwise dress a file up
+		if err := security.ValidateMIME(path.Base(newRel), head); err != nil {
+			status = http.StatusBadRequest
+			return fmt.Errorf("MIME type mismatch for %s: %w", newRel, err)
+		}
+
+		var ruleErr error
+		status, ruleErr = rules.check(newRel, info.Size(), head)
+		return ruleErr
+	})
+	if err != nil && status == http.StatusOK {
+		status = http.StatusInternalServerError
+	}
+	return status, err
+}
+
+// readHead reads the first sniffLen bytes of a file
+func readHead(fullPath string) ([]byte, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// normalizeRuleList lowercases and deduplicates list entries, dropping empty ones
+func normalizeRuleList(items []string, trim string) []string {
+	list := []string{}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		item = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(item), trim))
+		if item == "" || seen[item] || strings.Contains(item, ",") {
+			continue
+		}
+		seen[item] = true
+		list = append(list, item)
+	}
+	return list
+}
+
+// GetUploadRules handles listing upload rules
+func GetUploadRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := db.GetUploadRules()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch upload rules")
+		return
+	}
+	if rules == nil {
+		rules = []db.UploadRule{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+	})
+}
+
+// SetUploadRule handles creating or updating the upload rule for a directory
+func SetUploadRule(w http.ResponseWriter, r *http.Request) {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	var rule db.UploadRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	fullPath, err := security.ValidatePath(basePath, strings.TrimSpace(rule.Target))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid directory: "+err.Error())
+		return
+	}
+	rule.Target = security.GetRelativePath(basePath, fullPath)
+
+	for _, mode := range []*string{&rule.ExtensionMode, &rule.MIMEMode} {
+		if *mode == "" {
+			*mode = db.RuleModeBlock
+		}
+		if *mode != db.RuleModeAllow && *mode != db.RuleModeBlock {
+			writeError(w, http.StatusBadRequest, "Modes must be 'allow' or 'block'")
+			return
+		}
+	}
+
+	rule.Extensions = normalizeRuleList(rule.Extensions, ".")
+	rule.MIMETypes = normalizeRuleList(rule.MIMETypes, "")
+	for _, m := range rule.MIMETypes {
+		if major, minor, ok := strings.Cut(m, "/"); !ok || major == "" || minor == "" || major == "*" {
+			writeError(w, http.StatusBadRequest, "Invalid MIME type: "+m)
+			return
+		}
+	}
+
+	if rule.MaxSize < 0 {
+		writeError(w, http.StatusBadRequest, "Max size cannot be negative")
+		return
+	}
+	if rule.FilenamePattern != "" {
+		if _, err := security.CompileFilenamePattern(rule.FilenamePattern); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid filename pattern: "+err.Error())
+			return
+		}
+	}
+
+	if err := db.SetUploadRule(rule); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save upload rule")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Upload rule saved successfully",
+		"target":  rule.Target,
+	})
+}
+
+// DeleteUploadRule handles removing an upload rule
+func DeleteUploadRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "Invalid upload rule id")
+		return
+	}
+
+	if err := db.DeleteUploadRule(id); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete upload rule")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Upload rule deleted successfully",
+	})
+}
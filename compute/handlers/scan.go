@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"hextech-panel/clamav"
+	"hextech-panel/config"
+	"hextech-panel/db"
+	"hextech-panel/inspect"
+	"hextech-panel/security"
+)
+
+// Reason codes reported when the virus scan rejects an upload
+const (
+	codeMalwareDetected    = "malware_detected"
+	codeScannerUnavailable = "scanner_unavailable"
+)
+
+var (
+	scanner     *clamav.Client
+	scannerErr  error
+	scannerOnce sync.Once
+)
+
+// getScanner returns the shared clamd client, or nil when scanning is disabled
+func getScanner() (*clamav.Client, error) {
+	scannerOnce.Do(func() {
+		if config.Current.ClamdAddress == "" {
+			return
+		}
+		scanner, scannerErr = clamav.New(config.Current.ClamdAddress, time.Duration(config.Current.ClamdTimeoutSeconds)*time.Second)
+	})
+	return scanner, scannerErr
+}
+
+// quarantine moves infected content out of reach of the CDN, returning where it was stored
+func quarantine(basePath, hash, filename string, content []byte) (string, error) {
+	dir, err := filepath.Abs(config.Current.QuarantineDir)
+	if err != nil {
+		return "", err
+	}
+	if security.IsInsideDir(basePath, dir) {
+		return "", fmt.Errorf("quarantine directory is inside the CDN path")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(dir, hash+"-"+filename)
+	if err := os.WriteFile(dst, content, 0600); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// scanUpload runs content destined for relPath through clamd before it is written.
+// Infected content is quarantined; when clamd cannot scan, SCAN_FAIL_MODE decides.
+// Returns the HTTP status and a finding when the upload must be rejected.
+func scanUpload(ctx context.Context, basePath, relPath, hash string, content []byte, clientIP string) (int, *inspect.Finding) {
+	client, err := getScanner()
+	if client == nil && err == nil {
+		return http.StatusOK, nil
+	}
+
+	record := db.ScanResult{Path: relPath, SHA256: hash, SourceIP: clientIP}
+	var result clamav.Result
+	if err == nil {
+		result, err = client.Scan(ctx, bytes.NewReader(content))
+	}
+
+	if err != nil {
+		record.Status, record.Detail = db.ScanError, err.Error()
+		if rerr := db.RecordScan(record); rerr != nil {
+			slog.ErrorContext(ctx, "Failed to record scan", "path", relPath, "error", rerr)
+		}
+		if config.Current.ScanFailMode == "open" {
+			slog.WarnContext(ctx, "Virus scan failed, accepting unscanned upload", "path", relPath, "error", err)
+			return http.StatusOK, nil
+		}
+		reason := "virus scanner unavailable"
+		if errors.Is(err, clamav.ErrScanFailed) {
+			reason = "virus scan failed"
+		}
+		return http.StatusServiceUnavailable, &inspect.Finding{Code: codeScannerUnavailable, Reason: reason}
+	}
+
+	if !result.Infected {
+		record.Status = db.ScanClean
+		if err := db.RecordScan(record); err != nil {
+			slog.ErrorContext(ctx, "Failed to record scan", "path", relPath, "error", err)
+		}
+		return http.StatusOK, nil
+	}
+
+	record.Status, record.Detail = db.ScanInfected, result.Signature
+	if record.QuarantinePath, err = quarantine(basePath, hash, path.Base(relPath), content); err != nil {
+		slog.ErrorContext(ctx, "Failed to quarantine upload", "path", relPath, "error", err)
+	}
+	if err := db.RecordScan(record); err != nil {
+		slog.ErrorContext(ctx, "Failed to record scan", "path", relPath, "error", err)
+	}
+	return http.StatusUnprocessableEntity, &inspect.Finding{
+		Code:   codeMalwareDetected,
+		Reason: "malware detected (" + result.Signature + ")",
+	}
+}
+
+// GetScans handles listing virus scan results
+func GetScans(w http.ResponseWriter, r *http.Request) {
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+
+	limit := 50
+	offset := 0
+
+	if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+		offset = o
+	}
+
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	switch status {
+	case "", db.ScanClean, db.ScanInfected, db.ScanError:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	scans, err := db.GetScans(status, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch scan results")
+		return
+	}
+	if scans == nil {
+		scans = []db.ScanResult{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"scans":   scans,
+		"enabled": config.Current.ClamdAddress != "",
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
@@ -16,6 +16,39 @@ type SettingsResponse struct {
 	MaxUploadSize     int64    `json:"max_upload_size"`
 	BlockedExtensions []string `json:"blocked_extensions"`
 	PublicHostname    string   `json:"public_hostname"`
+	// FilenamePolicy controls how uploaded and renamed filenames are normalized
+	FilenamePolicy security.FilenamePolicy `json:"filename_policy"`
+}
+
+// filenamePolicyFromSettings builds the filename policy from stored settings,
+// falling back to the default for unset keys or an invalid combination
+func filenamePolicyFromSettings(settings map[string]string) security.FilenamePolicy {
+	policy := security.DefaultFilenamePolicy
+	if v, ok := settings["filename_case"]; ok {
+		policy.Case = v
+	}
+	if v, ok := settings["filename_charset"]; ok {
+		policy.Charset = v
+	}
+	if v, ok := settings["filename_space_replacement"]; ok {
+		policy.SpaceReplacement = v
+	}
+	if v, ok := settings["filename_invalid_replacement"]; ok {
+		policy.InvalidReplacement = v
+	}
+	if policy.Validate() != nil {
+		return security.DefaultFilenamePolicy
+	}
+	return policy
+}
+
+// getFilenamePolicy retrieves the configured filename policy
+func getFilenamePolicy() security.FilenamePolicy {
+	settings, err := db.GetAllSettings()
+	if err != nil {
+		return security.DefaultFilenamePolicy
+	}
+	return filenamePolicyFromSettings(settings)
 }
 
 // GetSettings handles fetching settings
@@ -28,21 +61,22 @@ func GetSettings(w http.ResponseWriter, r *http.Request) {
 
 	maxSize, _ := strconv.ParseInt(settings["max_upload_size"], 10, 64)
 	if maxSize == 0 {
-		maxSize = 100 * 1024 * 1024
+		maxSize = int64(config.Current.MaxUploadSize)
 	}
 
 	response := SettingsResponse{
 		BaseDirectory:     settings["base_directory"],
 		MaxUploadSize:     maxSize,
-		BlockedExtensions: security.ParseBlockedExtensions(settings["blocked_extensions"]),
+		BlockedExtensions: blockedExtensions(settings["blocked_extensions"]),
 		PublicHostname:    settings["public_hostname"],
+		FilenamePolicy:    filenamePolicyFromSettings(settings),
 	}
 
 	if response.BaseDirectory == "" {
-		response.BaseDirectory = config.CDNPath
+		response.BaseDirectory = config.Current.CDNPath
 	}
 	if response.PublicHostname == "" {
-		response.PublicHostname = config.PublicHostname
+		response.PublicHostname = config.Current.PublicHostname
 	}
 
 	writeJSON(w, http.StatusOK, response)
@@ -54,6 +88,8 @@ type UpdateSettingsRequest struct {
 	MaxUploadSize     *int64    `json:"max_upload_size,omitempty"`
 	BlockedExtensions *[]string `json:"blocked_extensions,omitempty"`
 	PublicHostname    *string   `json:"public_hostname,omitempty"`
+	// FilenamePolicy may be partial; omitted fields keep their current value
+	FilenamePolicy json.RawMessage `json:"filename_policy,omitempty"`
 }
 
 // UpdateSettings handles updating settings
@@ -69,6 +105,7 @@ func UpdateSettings(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusInternalServerError, "Failed to update base_directory")
 			return
 		}
+		WatchBaseDirectory()
 	}
 
 	if req.MaxUploadSize != nil {
@@ -99,6 +136,29 @@ func UpdateSettings(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if req.FilenamePolicy != nil {
+		policy := getFilenamePolicy()
+		if err := json.Unmarshal(req.FilenamePolicy, &policy); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid filename_policy")
+			return
+		}
+		if err := policy.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid filename_policy: "+err.Error())
+			return
+		}
+		for key, value := range map[string]string{
+			"filename_case":                policy.Case,
+			"filename_charset":             policy.Charset,
+			"filename_space_replacement":   policy.SpaceReplacement,
+			"filename_invalid_replacement": policy.InvalidReplacement,
+		} {
+			if err := db.SetSetting(key, value); err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to update filename_policy")
+				return
+			}
+		}
+	}
+
 	writeJSON(w, http.StatusOK, map[string]string{
 		"message": "Settings updated successfully",
 	})
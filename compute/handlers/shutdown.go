@@ -0,0 +1,30 @@
+package handlers
+
+// CloseEventStreams stops watching for changes and ends every open event stream. Streams
+// never finish on their own, so this runs when the server starts shutting down; clients
+// reconnect and resume.
+func CloseEventStreams() {
+	stopFileWatcher()
+	eventBroker.Close()
+}
+
+// StopBackgroundJobs cancels running export jobs and stops webhook delivery and cache
+// purging. Pending webhook deliveries stay queued for the next start; queued purges
+// are dropped. Workers that were never started are not started now.
+func StopBackgroundJobs() {
+	// Completing each Once prevents a late request from starting a worker
+	exportManagerOnce.Do(func() {})
+	if exportManager != nil {
+		exportManager.Shutdown()
+	}
+
+	webhookDispatcherOnce.Do(func() {})
+	if webhookDispatcher != nil {
+		webhookDispatcher.Shutdown()
+	}
+
+	cachePurgerOnce.Do(func() {})
+	if cachePurger != nil {
+		cachePurger.Shutdown()
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"hextech-panel/config"
+	"hextech-panel/imaging"
+	"hextech-panel/security"
+)
+
+// thumbnailSizes lists the bounding box sizes clients may request
+var thumbnailSizes = map[int]bool{64: true, 128: true, 256: true, 512: true}
+
+const defaultThumbnailSize = 256
+
+var (
+	imageCache     *imaging.Cache
+	imageCacheOnce sync.Once
+)
+
+// getImageCache returns the shared cache for thumbnails and transforms.
+// Both share one concurrency limit so together they can't saturate the CPU.
+func getImageCache() *imaging.Cache {
+	imageCacheOnce.Do(func() {
+		imageCache = imaging.NewCache(
+			filepath.Join(config.Current.CacheDir, "images"),
+			config.Current.ThumbnailConcurrency,
+		)
+	})
+	return imageCache
+}
+
+// GetThumbnail handles serving a downscaled preview of an image
+func GetThumbnail(w http.ResponseWriter, r *http.Request) {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	cache := getImageCache()
+	if security.IsInsideDir(basePath, cache.Dir()) {
+		writeError(w, http.StatusInternalServerError, "Cache directory must be outside the base directory")
+		return
+	}
+
+	requestedPath := r.URL.Query().Get("path")
+	if requestedPath == "" {
+		writeError(w, http.StatusBadRequest, "Path is required")
+		return
+	}
+
+	size := defaultThumbnailSize
+	if s := r.URL.Query().Get("size"); s != "" {
+		size, err = strconv.Atoi(s)
+		if err != nil || !thumbnailSizes[size] {
+			writeError(w, http.StatusBadRequest, "Size must be one of 64, 128, 256 or 512")
+			return
+		}
+	}
+
+	fullPath, err := security.ValidatePathExists(basePath, requestedPath)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if info.IsDir() {
+		writeError(w, http.StatusBadRequest, "Path is a directory")
+		return
+	}
+
+	if !imaging.IsSupported(info.Name()) {
+		writeError(w, http.StatusUnsupportedMediaType, "Thumbnails are not available for this file type")
+		return
+	}
+
+	hash, err := getFileHash(r.Context(), requestedPath, fullPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to compute hash")
+		return
+	}
+
+	serveImageVariant(w, r, fullPath, hash, "thumb"+strconv.Itoa(size), "private, max-age=86400",
+		func(img image.Image, _ string) (image.Image, string, int) {
+			thumb := imaging.Thumbnail(img, size)
+			if imaging.HasAlpha(thumb) {
+				return thumb, "png", 0
+			}
+			return thumb, "jpeg", 80
+		})
+}
+
+// imageRenderer turns a decoded source image into an output image, format and quality
+type imageRenderer func(img image.Image, sourceFormat string) (image.Image, string, int)
+
+// serveImageVariant writes a cached variant of an image, generating it on first request
+func serveImageVariant(w http.ResponseWriter, r *http.Request, fullPath, hash, variant, cacheControl string, render imageRenderer) {
+	etag := fmt.Sprintf(`"%s-%s"`, hash, variant)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	variantPath, err := getImageCache().Get(r.Context(), hash, variant, func(out io.Writer) error {
+		src, err := os.Open(fullPath)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		img, sourceFormat, err := imaging.Decode(src)
+		if err != nil {
+			return err
+		}
+
+		result, format, quality := render(img, sourceFormat)
+		return imaging.Encode(out, result, format, quality)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, imaging.ErrImageTooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, imaging.ErrUnsupportedFormat):
+			writeError(w, http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, r.Context().Err()):
+			// Client went away while waiting for a generation slot
+		default:
+			writeError(w, http.StatusUnprocessableEntity, "Failed to generate image")
+		}
+		return
+	}
+
+	f, err := os.Open(variantPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read image")
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read image")
+		return
+	}
+
+	// Content-Type is sniffed by ServeContent since the output format can depend on the source
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"image"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"hextech-panel/db"
+	"hextech-panel/imaging"
+	"hextech-panel/security"
+)
+
+// presetNameRegex restricts preset names to URL-safe identifiers
+var presetNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// parseTransformOptions reads w, h, fit, fm and q query parameters
+func parseTransformOptions(r *http.Request) (imaging.TransformOptions, error) {
+	q := r.URL.Query()
+	opts := imaging.TransformOptions{
+		Fit:     q.Get("fit"),
+		Format:  q.Get("fm"),
+		Quality: imaging.DefaultQuality,
+	}
+	if opts.Fit == "" {
+		opts.Fit = imaging.FitContain
+	}
+	if opts.Format == "jpg" {
+		opts.Format = "jpeg"
+	}
+
+	for key, dst := range map[string]*int{"w": &opts.Width, "h": &opts.Height, "q": &opts.Quality} {
+		if v := q.Get(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return opts, errors.New("invalid value for " + key)
+			}
+			*dst = n
+		}
+	}
+
+	return opts, opts.Validate()
+}
+
+// TransformImage handles public, preset-restricted image transforms.
+// The file path follows /transform and options come from either ?preset=name
+// or w/h/fit/fm/q parameters that must exactly match an allowlisted preset.
+func TransformImage(w http.ResponseWriter, r *http.Request) {
+	basePath, _, _, _, err := getSettings()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	if security.IsInsideDir(basePath, getImageCache().Dir()) {
+		writeError(w, http.StatusInternalServerError, "Cache directory must be outside the base directory")
+		return
+	}
+
+	var preset db.ImagePreset
+	if name := r.URL.Query().Get("preset"); name != "" {
+		preset, err = db.GetImagePreset(name)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Unknown preset")
+			return
+		}
+	} else {
+		opts, err := parseTransformOptions(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		preset, err = db.FindImagePreset(opts)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusForbidden, "Transform does not match an allowed preset")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "Failed to load presets")
+			return
+		}
+	}
+
+	requestedPath := strings.TrimPrefix(r.URL.Path, "/transform")
+	fullPath, err := security.ValidatePathExists(basePath, requestedPath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "File not found")
+		return
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil || info.IsDir() {
+		writeError(w, http.StatusNotFound, "File not found")
+		return
+	}
+
+	if !imaging.IsSupported(info.Name()) {
+		writeError(w, http.StatusUnsupportedMediaType, "Transforms are not available for this file type")
+		return
+	}
+
+	hash, err := getFileHash(r.Context(), security.GetRelativePath(basePath, fullPath), fullPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to compute hash")
+		return
+	}
+
+	opts := preset.TransformOptions
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	serveImageVariant(w, r, fullPath, hash, "t_"+opts.Key(), "public, max-age=300, must-revalidate",
+		func(img image.Image, sourceFormat string) (image.Image, string, int) {
+			return imaging.Transform(img, opts), opts.OutputFormat(sourceFormat), opts.Quality
+		})
+}
+
+// GetImagePresets handles listing image transform presets
+func GetImagePresets(w http.ResponseWriter, r *http.Request) {
+	presets, err := db.GetImagePresets()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch presets")
+		return
+	}
+	if presets == nil {
+		presets = []db.ImagePreset{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"presets": presets,
+	})
+}
+
+// SetImagePreset handles creating or updating an image transform preset
+func SetImagePreset(w http.ResponseWriter, r *http.Request) {
+	var req db.ImagePreset
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !presetNameRegex.MatchString(req.Name) {
+		writeError(w, http.StatusBadRequest, "Preset name must be lowercase letters, digits, dashes or underscores")
+		return
+	}
+	if req.Fit == "" {
+		req.Fit = imaging.FitContain
+	}
+	if req.Quality == 0 {
+		req.Quality = imaging.DefaultQuality
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := db.SetImagePreset(req); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save preset")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Preset saved successfully",
+		"name":    req.Name,
+	})
+}
+
+// DeleteImagePreset handles removing an image transform preset
+func DeleteImagePreset(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "Preset name is required")
+		return
+	}
+
+	if err := db.DeleteImagePreset(name); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete preset")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Preset deleted successfully",
+	})
+}
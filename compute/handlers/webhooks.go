@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"hextech-panel/config"
+	"hextech-panel/db"
+	"hextech-panel/webhook"
+)
+
+var (
+	webhookDispatcher     *webhook.Dispatcher
+	webhookDispatcherOnce sync.Once
+)
+
+// getWebhookDispatcher returns the shared webhook dispatcher
+func getWebhookDispatcher() *webhook.Dispatcher {
+	webhookDispatcherOnce.Do(func() {
+		// The networks were validated when the configuration was loaded
+		allowed, _ := webhook.ParseNetworks(config.Current.WebhookAllowedNetworks)
+		webhookDispatcher = webhook.NewDispatcher(webhook.Config{
+			MaxAttempts:     config.Current.WebhookMaxAttempts,
+			Timeout:         time.Duration(config.Current.WebhookTimeoutSeconds) * time.Second,
+			AllowedNetworks: allowed,
+		})
+	})
+	return webhookDispatcher
+}
+
+// StartWebhooks starts delivering webhooks, resuming deliveries queued before a restart
+func StartWebhooks() {
+	getWebhookDispatcher()
+}
+
+// notifyWebhooks queues a file event for subscribed webhooks. oldPath is set for
+// events that move content from one path to another.
+func notifyWebhooks(r *http.Request, event, relPath, oldPath string, isDir bool) {
+	e := webhook.Event{
+		Type:     event,
+		Path:     relPath,
+		OldPath:  oldPath,
+		IsDir:    isDir,
+		Actor:    getUserEmail(r),
+		SourceIP: getClientIP(r),
+	}
+	if !isDir && event != webhook.EventDelete {
+		if _, _, _, publicHost, err := getSettings(); err == nil {
+			e.URL = publicURL(publicHost, relPath)
+		}
+	}
+
+	if err := getWebhookDispatcher().Publish(e); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to queue webhook", "event", event, "path", relPath, "error", err)
+	}
+}
+
+// isDirectory reports whether fullPath is a directory, without following symlinks
+func isDirectory(fullPath string) bool {
+	info, err := os.Lstat(fullPath)
+	return err == nil && info.IsDir()
+}
+
+// webhookRequest is the body accepted when creating or updating a webhook
+type webhookRequest struct {
+	URL          string   `json:"url"`
+	Secret       string   `json:"secret"`
+	RotateSecret bool     `json:"rotate_secret"`
+	Events       []string `json:"events"`
+	PathPrefixes []string `json:"path_prefixes"`
+	Active       *bool    `json:"active"`
+}
+
+// apply validates the request and copies it onto a webhook
+func (req webhookRequest) apply(h *db.Webhook) error {
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("URL must be an absolute http or https URL")
+	}
+	h.URL = u.String()
+
+	events := normalizeRuleList(req.Events, "")
+	for _, e := range events {
+		if !webhook.ValidEvent(e) {
+			return errors.New("unknown event '" + e + "', expected one of " + strings.Join(webhook.Events, ", "))
+		}
+	}
+	h.Events = events
+
+	prefixes := []string{}
+	for _, p := range req.PathPrefixes {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if strings.Contains(p, ",") {
+			return errors.New("path prefixes cannot contain commas")
+		}
+		prefixes = append(prefixes, path.Clean("/"+p))
+	}
+	h.PathPrefixes = prefixes
+
+	switch {
+	case req.Secret != "":
+		h.Secret = req.Secret
+	case req.RotateSecret || h.Secret == "":
+		h.Secret = webhook.NewSecret()
+	}
+	if req.Active != nil {
+		h.Active = *req.Active
+	}
+	return nil
+}
+
+// parseID reads a positive integer query parameter
+func parseID(r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// GetWebhooks handles listing webhooks
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := db.GetWebhooks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch webhooks")
+		return
+	}
+	if hooks == nil {
+		hooks = []db.Webhook{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"webhooks": hooks,
+		"events":   webhook.Events,
+	})
+}
+
+// CreateWebhook handles adding a webhook. The signing secret is only returned here
+// and when it is rotated.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	hook := db.Webhook{Active: true}
+	if err := req.apply(&hook); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := db.CreateWebhook(hook)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save webhook")
+		return
+	}
+	if saved, err := db.GetWebhook(id); err == nil {
+		hook = saved
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"message": "Webhook created successfully",
+		"webhook": hook,
+		"secret":  hook.Secret,
+	})
+}
+
+// UpdateWebhook handles replacing a webhook's URL and subscriptions, pausing it or rotating its secret
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(r, "id")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid webhook id")
+		return
+	}
+
+	hook, err := db.GetWebhook(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch webhook")
+		return
+	}
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	oldSecret := hook.Secret
+	if err := req.apply(&hook); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := db.UpdateWebhook(hook); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save webhook")
+		return
+	}
+	// Reactivating a webhook releases deliveries held while it was disabled
+	getWebhookDispatcher().Wake()
+
+	response := map[string]interface{}{
+		"message": "Webhook updated successfully",
+		"webhook": hook,
+	}
+	if hook.Secret != oldSecret {
+		response["secret"] = hook.Secret
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// DeleteWebhook handles removing a webhook along with its delivery history
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(r, "id")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid webhook id")
+		return
+	}
+
+	if err := db.DeleteWebhook(id); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// GetWebhookDeliveries handles listing delivery history, optionally for one webhook
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+
+	limit := 50
+	offset := 0
+
+	if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+		offset = o
+	}
+
+	webhookID, ok := parseID(r, "webhook_id")
+	if !ok {
+		webhookID = 0
+	}
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	switch status {
+	case "", db.DeliveryPending, db.DeliveryDelivered, db.DeliveryFailed:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	deliveries, err := db.GetDeliveries(webhookID, status, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []db.WebhookDelivery{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// RedeliverWebhook handles queueing a delivery's payload again, e.g. after fixing the receiver
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(r, "id")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid delivery id")
+		return
+	}
+
+	newID, err := db.Redeliver(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Delivery not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to queue redelivery")
+		return
+	}
+	getWebhookDispatcher().Wake()
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message":     "Redelivery queued",
+		"delivery_id": newID,
+	})
+}
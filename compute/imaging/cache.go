@@ -0,0 +1,103 @@
+package imaging
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Cache stores generated image variants on disk, keyed by source hash.
+// Generation is bounded by a semaphore so large folders don't saturate the CPU.
+type Cache struct {
+	dir string
+	sem chan struct{}
+}
+
+// NewCache creates a cache rooted at dir that runs at most concurrency generators at once
+func NewCache(dir string, concurrency int) *Cache {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Cache{
+		dir: dir,
+		sem: make(chan struct{}, concurrency),
+	}
+}
+
+// Dir returns the cache root directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// path returns the on-disk location of a variant.
+// Files are sharded by the first two hash characters to keep directories small.
+func (c *Cache) path(hash, variant string) string {
+	shard := "00"
+	if len(hash) >= 2 {
+		shard = hash[:2]
+	}
+	return filepath.Join(c.dir, shard, hash+"_"+variant)
+}
+
+// Get returns the path of a cached variant, generating it first if needed.
+// The generate function writes the variant's bytes; it runs while holding a concurrency slot.
+func (c *Cache) Get(ctx context.Context, hash, variant string, generate func(w io.Writer) error) (string, error) {
+	target := c.path(hash, variant)
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	// Another request may have produced it while we waited
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := generate(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	// Rename is atomic, so readers never see a partially written variant
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// Invalidate removes every cached variant for a source hash
+func (c *Cache) Invalidate(hash string) error {
+	if hash == "" {
+		return nil
+	}
+	matches, err := filepath.Glob(c.path(hash, "*"))
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := os.Remove(m); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooLarge     = errors.New("image dimensions exceed the allowed limit")
+)
+
+// MaxPixels caps the decoded size of a source image to guard against decompression bombs
+// 50 megapixels at 4 bytes per pixel is roughly 200MB of memory
+const MaxPixels = 50 * 1000 * 1000
+
+// supportedExtensions lists source image types that can be decoded in pure Go
+var supportedExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// IsSupported reports whether a filename has a decodable image extension
+func IsSupported(filename string) bool {
+	return supportedExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// Decode reads an image after checking its dimensions against MaxPixels
+func Decode(r io.ReadSeeker) (image.Image, string, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, "", ErrUnsupportedFormat
+		}
+		return nil, "", err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	return image.Decode(r)
+}
+
+// Thumbnail scales an image to fit within a maxSize x maxSize box, preserving aspect ratio.
+// Images that already fit are returned unchanged.
+func Thumbnail(img image.Image, maxSize int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSize && h <= maxSize {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*maxSize/w)
+		w = maxSize
+	} else {
+		w = max(1, w*maxSize/h)
+		h = maxSize
+	}
+
+	return Resize(img, w, h)
+}
+
+// Resize scales an image to exactly w x h pixels
+func Resize(img image.Image, w, h int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// HasAlpha reports whether an image contains any non-opaque pixels
+func HasAlpha(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	return true
+}
+
+// Encode writes an image in the given format ("jpeg", "png" or "webp").
+// WebP output is always lossless, so quality only applies to JPEG.
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "jpeg":
+		if quality <= 0 || quality > 100 {
+			quality = DefaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "png":
+		enc := png.Encoder{CompressionLevel: png.BestSpeed}
+		return enc.Encode(w, img)
+	case "webp":
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+// ContentType returns the MIME type for an output format
+func ContentType(format string) string {
+	switch format {
+	case "jpeg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	case "webp":
+		return "image/webp"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Fit modes for Transform
+const (
+	FitContain = "contain" // scale to fit inside the box, preserving aspect ratio
+	FitCover   = "cover"   // scale to fill the box, cropping the overflow
+	FitFill    = "fill"    // stretch to exactly the box dimensions
+)
+
+// MaxDimension caps the width and height a transform may produce
+const MaxDimension = 4096
+
+// DefaultQuality is the JPEG quality used when a transform does not give one
+const DefaultQuality = 85
+
+var ErrInvalidTransform = errors.New("invalid transform options")
+
+// TransformOptions describes a resize and re-encode of a source image.
+// A zero Width or Height means "derive from the other dimension".
+// An empty Format keeps the source format. Quality only applies to JPEG output.
+type TransformOptions struct {
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Fit     string `json:"fit"`
+	Format  string `json:"format"`
+	Quality int    `json:"quality"`
+}
+
+// Validate checks that the options are within supported bounds
+func (o TransformOptions) Validate() error {
+	if o.Width < 0 || o.Height < 0 || o.Width > MaxDimension || o.Height > MaxDimension {
+		return fmt.Errorf("%w: dimensions must be between 0 and %d", ErrInvalidTransform, MaxDimension)
+	}
+	if o.Width == 0 && o.Height == 0 {
+		return fmt.Errorf("%w: width or height is required", ErrInvalidTransform)
+	}
+	switch o.Fit {
+	case FitContain, FitCover, FitFill:
+	default:
+		return fmt.Errorf("%w: fit must be contain, cover or fill", ErrInvalidTransform)
+	}
+	switch o.Format {
+	case "", "jpeg", "png", "webp":
+	default:
+		return fmt.Errorf("%w: format must be jpeg, png or webp", ErrInvalidTransform)
+	}
+	if o.Quality < 1 || o.Quality > 100 {
+		return fmt.Errorf("%w: quality must be between 1 and 100", ErrInvalidTransform)
+	}
+	return nil
+}
+
+// Key returns a stable identifier for the options, used as a cache variant name
+func (o TransformOptions) Key() string {
+	return fmt.Sprintf("w%d_h%d_%s_%s_q%d", o.Width, o.Height, o.Fit, o.Format, o.Quality)
+}
+
+// OutputFormat resolves the encoding format for a decoded source format
+func (o TransformOptions) OutputFormat(sourceFormat string) string {
+	if o.Format != "" {
+		return o.Format
+	}
+	switch sourceFormat {
+	case "jpeg", "png", "webp":
+		return sourceFormat
+	default:
+		return "png"
+	}
+}
+
+// Transform resizes an image according to the options
+func Transform(img image.Image, o TransformOptions) image.Image {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+
+	w, h := o.Width, o.Height
+	if w == 0 {
+		w = max(1, srcW*h/srcH)
+	}
+	if h == 0 {
+		h = max(1, srcH*w/srcW)
+	}
+
+	switch o.Fit {
+	case FitFill:
+		return Resize(img, w, h)
+
+	case FitCover:
+		// Scale so the image covers the box, then crop the center
+		scaleW, scaleH := w, srcH*w/srcW
+		if scaleH < h {
+			scaleW, scaleH = srcW*h/srcH, h
+		}
+		scaled := Resize(img, max(1, scaleW), max(1, scaleH))
+		offX := (scaled.Bounds().Dx() - w) / 2
+		offY := (scaled.Bounds().Dy() - h) / 2
+		dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(dst, dst.Bounds(), scaled, image.Pt(offX, offY), draw.Src)
+		return dst
+
+	default: // contain
+		fitW, fitH := w, srcH*w/srcW
+		if fitH > h {
+			fitW, fitH = srcW*h/srcH, h
+		}
+		return Resize(img, max(1, fitW), max(1, fitH))
+	}
+}
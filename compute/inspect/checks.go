@@ -0,0 +1,140 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"hextech-panel/textenc"
+)
+
+var bomUTF8 = []byte{0xEF, 0xBB, 0xBF}
+
+// imageExts are raster formats that must never contain markup
+var imageExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+	".bmp": true, ".ico": true, ".avif": true, ".tif": true, ".tiff": true,
+}
+
+// Executable detects native executables by their magic numbers
+func Executable(filename string, content []byte) *Finding {
+	switch {
+	case bytes.HasPrefix(content, []byte("\x7fELF")):
+		return &Finding{Code: CodeELF, Reason: "content is an ELF executable"}
+	case isPE(content):
+		return &Finding{Code: CodePE, Reason: "content is a Windows PE executable"}
+	case isMachO(content):
+		return &Finding{Code: CodeMachO, Reason: "content is a Mach-O executable"}
+	}
+	return nil
+}
+
+// isPE checks for an MZ header whose e_lfanew field points at a PE signature
+func isPE(content []byte) bool {
+	if len(content) < 0x40 || !bytes.HasPrefix(content, []byte("MZ")) {
+		return false
+	}
+	offset := int64(binary.LittleEndian.Uint32(content[0x3C:]))
+	return offset+4 <= int64(len(content)) && bytes.Equal(content[offset:offset+4], []byte("PE\x00\x00"))
+}
+
+// isMachO checks for thin Mach-O magics in either byte order, and for fat binaries
+func isMachO(content []byte) bool {
+	if len(content) < 8 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(content) {
+	case 0xFEEDFACE, 0xFEEDFACF, 0xCEFAEDFE, 0xCFFAEDFE:
+		return true
+	case 0xCAFEBABE:
+		// Java class files share this magic; there the next field is a version, not a small arch count
+		return binary.BigEndian.Uint32(content[4:]) < 30
+	}
+	return false
+}
+
+// Shebang detects interpreter scripts regardless of their extension
+func Shebang(filename string, content []byte) *Finding {
+	if bytes.HasPrefix(bytes.TrimPrefix(content, bomUTF8), []byte("#!")) {
+		return &Finding{Code: CodeShebang, Reason: "content is a script with a #! interpreter line"}
+	}
+	return nil
+}
+
+// EmbeddedPHP detects PHP code: anywhere in binary files, or as the start of a text file
+func EmbeddedPHP(filename string, content []byte) *Finding {
+	if _, err := textenc.Detect(content); err == nil {
+		head := bytes.TrimLeft(bytes.TrimPrefix(content, bomUTF8), " \t\r\n")
+		head = asciiLower(head[:min(len(head), 5)])
+		if !bytes.HasPrefix(head, []byte("<?php")) && !bytes.HasPrefix(head, []byte("<?=")) {
+			return nil
+		}
+	} else if !bytes.Contains(asciiLower(content), []byte("<?php")) {
+		return nil
+	}
+	return &Finding{Code: CodeEmbeddedPHP, Reason: "content contains PHP code"}
+}
+
+// archiveExts are formats that legitimately end in a ZIP central directory
+var archiveExts = map[string]bool{
+	".zip": true, ".jar": true, ".apk": true, ".epub": true, ".xpi": true, ".kmz": true,
+	".docx": true, ".xlsx": true, ".pptx": true, ".odt": true, ".ods": true, ".odp": true,
+	".whl": true, ".nupkg": true, ".3mf": true,
+}
+
+// maxZipComment is the largest comment a ZIP end-of-central-directory record can carry
+const maxZipComment = 0xFFFF
+
+// Polyglot detects files that are also valid as a second format: a ZIP archive
+// appended to another file (e.g. GIFAR) or a PDF header hidden behind another format
+func Polyglot(filename string, content []byte) *Finding {
+	e := ext(filename)
+	if !archiveExts[e] && !bytes.HasPrefix(content, []byte("PK")) && hasZipDirectory(content) {
+		return &Finding{Code: CodePolyglotZip, Reason: "content also parses as a ZIP archive"}
+	}
+
+	// PDF readers accept the header anywhere in the first kilobyte
+	if e != ".pdf" {
+		head := content[:min(len(content), 1024)]
+		if bytes.Index(head, []byte("%PDF-")) > 0 {
+			return &Finding{Code: CodePolyglotPDF, Reason: "content also parses as a PDF document"}
+		}
+	}
+	return nil
+}
+
+// hasZipDirectory looks for an end-of-central-directory record whose comment runs
+// exactly to the end of the content, as a ZIP reader would locate it
+func hasZipDirectory(content []byte) bool {
+	const eocdLen = 22
+	start := max(0, len(content)-eocdLen-maxZipComment)
+	for i := len(content) - eocdLen; i >= start; i-- {
+		if content[i] != 'P' || !bytes.Equal(content[i:i+4], []byte("PK\x05\x06")) {
+			continue
+		}
+		commentLen := int(binary.LittleEndian.Uint16(content[i+20:]))
+		if i+eocdLen+commentLen == len(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlMarkers are sequences a browser sniffing an image as HTML would act on
+var htmlMarkers = []string{
+	"<!doctype html", "<html", "<head", "<body", "<script", "<iframe",
+	"<object", "<embed", "<meta http-equiv",
+}
+
+// HTMLInImage detects markup hidden in raster images, e.g. in metadata or after the image data
+func HTMLInImage(filename string, content []byte) *Finding {
+	if !imageExts[ext(filename)] {
+		return nil
+	}
+	lower := asciiLower(content)
+	for _, marker := range htmlMarkers {
+		if bytes.Contains(lower, []byte(marker)) {
+			return &Finding{Code: CodeHTMLInImage, Reason: "image contains HTML markup (" + marker + ")"}
+		}
+	}
+	return nil
+}
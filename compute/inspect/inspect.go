@@ -0,0 +1,82 @@
+package inspect
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Reason codes reported when content is rejected
+const (
+	CodeELF               = "executable_elf"
+	CodePE                = "executable_pe"
+	CodeMachO             = "executable_macho"
+	CodeShebang           = "script_shebang"
+	CodeEmbeddedPHP       = "embedded_php"
+	CodePolyglotZip       = "polyglot_zip"
+	CodePolyglotPDF       = "polyglot_pdf"
+	CodeSVGScript         = "svg_script"
+	CodeSVGEventHandler   = "svg_event_handler"
+	CodeSVGExternalRef    = "svg_external_reference"
+	CodeSVGForeignContent = "svg_foreign_content"
+	CodeHTMLInImage       = "html_in_image"
+)
+
+// Finding describes why content was rejected
+type Finding struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// Error implements error so a finding can be returned as one
+func (f *Finding) Error() string {
+	return f.Reason
+}
+
+// Check inspects a file's content, returning a finding to reject it or nil to pass
+type Check func(filename string, content []byte) *Finding
+
+// Pipeline runs a sequence of checks, stopping at the first finding
+type Pipeline struct {
+	checks []Check
+}
+
+// NewPipeline creates a pipeline running checks in order
+func NewPipeline(checks ...Check) *Pipeline {
+	return &Pipeline{checks: checks}
+}
+
+// Add appends a check to the pipeline
+func (p *Pipeline) Add(check Check) {
+	p.checks = append(p.checks, check)
+}
+
+// Inspect runs every check over the content and returns the first finding, if any
+func (p *Pipeline) Inspect(filename string, content []byte) *Finding {
+	for _, check := range p.checks {
+		if f := check(filename, content); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// Default is the pipeline applied to uploaded and edited files
+var Default = NewPipeline(Executable, Shebang, EmbeddedPHP, Polyglot, SVG, HTMLInImage)
+
+// ext returns a filename's lowercased extension
+func ext(filename string) string {
+	return strings.ToLower(filepath.Ext(filename))
+}
+
+// asciiLower returns a copy of b with ASCII letters lowercased, for case-insensitive
+// searches over content that may not be valid UTF-8
+func asciiLower(b []byte) []byte {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	return lower
+}
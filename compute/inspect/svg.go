@@ -0,0 +1,315 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidSVG is returned when an SVG cannot be parsed for sanitization
+var ErrInvalidSVG = errors.New("invalid SVG")
+
+var (
+	svgEventHandler = regexp.MustCompile(`(?i)[\s"'/]on[a-z]+\s*=`)
+	svgReference    = regexp.MustCompile(`(?i)(?:href|src)\s*=\s*["']\s*([^"']*)|url\(\s*["']?\s*([^"')\s]*)`)
+	cssReference    = regexp.MustCompile(`(?i)url\(\s*["']?\s*([^"')\s]*)`)
+)
+
+// svgSafeReference reports whether an SVG reference stays inside the document
+func svgSafeReference(ref string) bool {
+	ref = strings.ToLower(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return true
+	}
+	for _, prefix := range []string{"data:image/png", "data:image/jpeg", "data:image/gif", "data:image/webp"} {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SVG detects active content in SVG images: scripts, event handlers, embedded
+// HTML and references to anything outside the document
+func SVG(filename string, content []byte) *Finding {
+	if ext(filename) != ".svg" {
+		return nil
+	}
+	lower := asciiLower(content)
+
+	for _, token := range []string{"<script", "javascript:", "vbscript:"} {
+		if bytes.Contains(lower, []byte(token)) {
+			return &Finding{Code: CodeSVGScript, Reason: "SVG contains a script (" + token + ")"}
+		}
+	}
+	if m := svgEventHandler.Find(content); m != nil {
+		return &Finding{Code: CodeSVGEventHandler, Reason: "SVG contains an event handler (" + strings.TrimSpace(string(m[1:])) + ")"}
+	}
+	for _, token := range []string{"<foreignobject", "<iframe", "<embed", "<object"} {
+		if bytes.Contains(lower, []byte(token)) {
+			return &Finding{Code: CodeSVGForeignContent, Reason: "SVG embeds foreign content (" + token + ")"}
+		}
+	}
+
+	for _, token := range []string{"<!entity", "@import"} {
+		if bytes.Contains(lower, []byte(token)) {
+			return &Finding{Code: CodeSVGExternalRef, Reason: "SVG references external content (" + token + ")"}
+		}
+	}
+	for _, m := range svgReference.FindAllSubmatch(content, -1) {
+		ref := string(m[1]) + string(m[2])
+		if !svgSafeReference(strings.TrimSpace(ref)) {
+			if len(ref) > 64 {
+				ref = ref[:64] + "..."
+			}
+			return &Finding{Code: CodeSVGExternalRef, Reason: "SVG references external content (" + ref + ")"}
+		}
+	}
+	return nil
+}
+
+// Kinds of content removed by SanitizeSVG
+const (
+	RemovedElement      = "element"
+	RemovedEventHandler = "event_handler"
+	RemovedScriptURL    = "script_url"
+	RemovedExternalRef  = "external_reference"
+	RemovedComment      = "comment"
+	RemovedInstruction  = "instruction"
+	RemovedDoctype      = "doctype"
+)
+
+// Removal reports content stripped from an SVG
+type Removal struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// removalLog counts removals in the order they were first seen
+type removalLog []Removal
+
+func (l *removalLog) add(kind, name string) {
+	for i := range *l {
+		if (*l)[i].Kind == kind && (*l)[i].Name == name {
+			(*l)[i].Count++
+			return
+		}
+	}
+	*l = append(*l, Removal{Kind: kind, Name: name, Count: 1})
+}
+
+// svgBlockedElements are removed together with everything inside them
+var svgBlockedElements = map[string]bool{
+	"script": true, "foreignobject": true, "iframe": true, "embed": true,
+	"object": true, "handler": true, "listener": true,
+}
+
+// svgAnimations can rewrite another element's attributes
+var svgAnimations = map[string]bool{
+	"set": true, "animate": true, "animatecolor": true, "animatemotion": true, "animatetransform": true,
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;",
+		"\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+)
+
+// qualifiedName renders a raw token name with its namespace prefix
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// compactLower lowercases a value and drops whitespace and control characters,
+// which browsers ignore inside URL schemes such as "java\tscript:"
+func compactLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// hasScriptURL reports whether a value contains a URL that runs code
+func hasScriptURL(value string) bool {
+	v := compactLower(value)
+	return strings.Contains(v, "javascript:") || strings.Contains(v, "vbscript:") || strings.Contains(v, "data:text/html")
+}
+
+// unsafeCSS reports whether a style value loads or runs anything outside the document
+func unsafeCSS(css string) bool {
+	lower := strings.ToLower(css)
+	if strings.Contains(lower, "@import") || strings.Contains(lower, "expression(") || hasScriptURL(css) {
+		return true
+	}
+	for _, m := range cssReference.FindAllStringSubmatch(css, -1) {
+		if !svgSafeReference(strings.TrimSpace(m[1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsafeAttr classifies an attribute that must be removed, returning "" for safe ones
+func unsafeAttr(a xml.Attr) string {
+	local := strings.ToLower(a.Name.Local)
+	switch {
+	case a.Name.Space == "" && strings.HasPrefix(local, "on"):
+		return RemovedEventHandler
+	case hasScriptURL(a.Value):
+		return RemovedScriptURL
+	case local == "href" || local == "src":
+		if !svgSafeReference(strings.TrimSpace(a.Value)) {
+			return RemovedExternalRef
+		}
+	case a.Name.Space == "xml" && local == "base":
+		return RemovedExternalRef
+	case unsafeCSS(a.Value):
+		return RemovedExternalRef
+	}
+	return ""
+}
+
+// blockedElement reports whether an element must be removed with its contents
+func blockedElement(t xml.StartElement) bool {
+	local := strings.ToLower(t.Name.Local)
+	if svgBlockedElements[local] {
+		return true
+	}
+	if svgAnimations[local] {
+		for _, a := range t.Attr {
+			if strings.EqualFold(a.Name.Local, "attributeName") {
+				target := strings.ToLower(a.Value)
+				return strings.HasPrefix(target, "on") || strings.HasSuffix(target, "href")
+			}
+		}
+	}
+	return false
+}
+
+// SanitizeSVG parses an SVG document and strips scripts, event handlers, script URLs,
+// foreign content, external references, comments, DOCTYPEs and processing instructions.
+// It returns the cleaned document and what was removed.
+func SanitizeSVG(content []byte) ([]byte, []Removal, error) {
+	dec := xml.NewDecoder(bytes.NewReader(bytes.TrimPrefix(content, bomUTF8)))
+	dec.Strict = true
+
+	var out bytes.Buffer
+	var removed removalLog
+	var stack []string
+	skip := 0        // depth inside a removed element
+	styleStart := -1 // output offset of the open <style> element
+	sawRoot := false
+
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSVG, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			name := qualifiedName(t.Name)
+			if len(stack) == 0 {
+				if sawRoot || !strings.EqualFold(t.Name.Local, "svg") {
+					return nil, nil, ErrInvalidSVG
+				}
+				sawRoot = true
+			}
+			if blockedElement(t) {
+				removed.add(RemovedElement, name)
+				skip = 1
+				continue
+			}
+
+			if strings.EqualFold(t.Name.Local, "style") {
+				styleStart = out.Len()
+			}
+			stack = append(stack, name)
+			out.WriteString("<" + name)
+			for _, a := range t.Attr {
+				if kind := unsafeAttr(a); kind != "" {
+					removed.add(kind, qualifiedName(a.Name))
+					continue
+				}
+				out.WriteString(" " + qualifiedName(a.Name) + `="` + attrEscaper.Replace(a.Value) + `"`)
+			}
+			out.WriteString(">")
+
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			name := qualifiedName(t.Name)
+			if len(stack) == 0 || stack[len(stack)-1] != name {
+				return nil, nil, ErrInvalidSVG
+			}
+			stack = stack[:len(stack)-1]
+			out.WriteString("</" + name + ">")
+
+			// A stylesheet is only judged once all of its text has been seen
+			if styleStart >= 0 && strings.EqualFold(t.Name.Local, "style") {
+				if unsafeCSS(out.String()[styleStart:]) {
+					out.Truncate(styleStart)
+					removed.add(RemovedElement, name)
+				}
+				styleStart = -1
+			}
+
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if len(stack) == 0 {
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, nil, ErrInvalidSVG
+				}
+			}
+			out.WriteString(textEscaper.Replace(string(t)))
+
+		case xml.Comment:
+			if skip == 0 {
+				removed.add(RemovedComment, "comment")
+			}
+
+		case xml.ProcInst:
+			if t.Target == "xml" && out.Len() == 0 {
+				out.WriteString("<?xml " + string(t.Inst) + "?>")
+				continue
+			}
+			if skip == 0 {
+				removed.add(RemovedInstruction, t.Target)
+			}
+
+		case xml.Directive:
+			if skip == 0 {
+				removed.add(RemovedDoctype, "DOCTYPE")
+			}
+		}
+	}
+
+	if !sawRoot || len(stack) > 0 {
+		return nil, nil, ErrInvalidSVG
+	}
+	return out.Bytes(), removed, nil
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Setup makes a JSON or text logger at the given level the default for both
+// log/slog and the standard log package
+func Setup(w io.Writer, level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q: use debug, info, warn or error", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q: use json or text", format)
+	}
+
+	slog.SetDefault(slog.New(&contextHandler{h}))
+	return nil
+}
+
+// requestInfo identifies the request a log line was written for
+type requestInfo struct {
+	id    string
+	user  string
+	start time.Time
+}
+
+type requestInfoKey struct{}
+
+// WithRequest returns a context whose log lines carry the request ID, route and the
+// latency so far, and the user once SetUser has been called
+func WithRequest(ctx context.Context, id string, start time.Time) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, &requestInfo{id: id, start: start})
+}
+
+// SetUser records the authenticated user of the request ctx belongs to, for every line
+// logged for it from then on, including the request's own log line
+func SetUser(ctx context.Context, user string) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.user = user
+	}
+}
+
+// contextHandler adds the request attributes from a log call's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		rec.AddAttrs(slog.String("request_id", info.id))
+		if info.user != "" {
+			rec.AddAttrs(slog.String("user", info.user))
+		}
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			rec.AddAttrs(slog.String("route", rctx.RoutePattern()))
+		}
+		rec.AddAttrs(slog.Float64("latency_ms", float64(time.Since(info.start).Microseconds())/1000))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
@@ -14,44 +21,104 @@ import (
 	"hextech-panel/config"
 	"hextech-panel/db"
 	"hextech-panel/handlers"
+	"hextech-panel/logging"
+	"hextech-panel/metrics"
 	"hextech-panel/middleware"
 )
 
 func main() {
-	// Initialize configuration from environment variables
-	config.Init()
-	log.Printf("Config loaded: CDN_PATH=%s, PUBLIC_HOSTNAME=%s, ALLOWED_ORIGINS=%v",
-		config.CDNPath, config.PublicHostname, config.AllowedOrigins)
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration `file`; environment variables override it")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config file] [config print]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(*configFile, flag.Args()))
+	}
+
+	// Initialize configuration from the config file and environment variables
+	if err := config.Init(*configFile); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		printErrors(err)
+		os.Exit(1)
+	}
+	if err := logging.Setup(os.Stderr, config.Current.LogLevel, config.Current.LogFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.Info("Config loaded", "config_file", config.Current.File(), "cdn_path", config.Current.CDNPath,
+		"public_hostname", config.Current.PublicHostname, "allowed_origins", config.Current.AllowedOrigins)
 
 	// Initialize database
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "./hextech.db"
+	if err := db.Init(config.Current.DBPath); err != nil {
+		slog.Error("Failed to initialize database", "error", err)
+		os.Exit(1)
 	}
 
-	if err := db.Init(dbPath); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// Deliver webhooks queued before a restart
+	handlers.StartWebhooks()
+
+	// Stream changes made to the files outside the panel
+	handlers.WatchBaseDirectory()
+
+	if config.Current.ClamdAddress != "" {
+		slog.Info("Virus scanning enabled", "clamd_address", config.Current.ClamdAddress, "scan_fail_mode", config.Current.ScanFailMode)
+	}
+
+	// Serve Prometheus metrics on their own listener, never through the tunnel
+	var metricsServer *http.Server
+	if config.Current.MetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:              config.Current.MetricsAddress,
+			Handler:           mux,
+			ReadHeaderTimeout: seconds(config.Current.ReadHeaderTimeoutSeconds),
+			WriteTimeout:      seconds(config.Current.WriteTimeoutSeconds),
+		}
+		go func() {
+			slog.Info("Serving metrics", "addr", config.Current.MetricsAddress)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Metrics server failed", "error", err)
+				os.Exit(1)
+			}
+		}()
 	}
-	defer db.Close()
+
+	// Routes that move large bodies get the upload timeout instead of the defaults
+	transfer := middleware.Deadlines(seconds(config.Current.UploadTimeoutSeconds), seconds(config.Current.UploadTimeoutSeconds))
 
 	// Create router
 	r := chi.NewRouter()
 
 	// Global middleware
-	r.Use(chimiddleware.Logger)
-	r.Use(chimiddleware.Recoverer)
+	r.Use(chimiddleware.RequestID)
 	r.Use(chimiddleware.RealIP)
+	if config.Current.TLSClientCAFile != "" {
+		// Client certificates identify users, so set the identity before anything reads it
+		r.Use(middleware.ClientCertIdentity)
+	}
+	r.Use(middleware.RequestLogger)
+	r.Use(middleware.Metrics)
+	r.Use(chimiddleware.Recoverer)
+	r.Use(middleware.Deadlines(seconds(config.Current.ReadTimeoutSeconds), seconds(config.Current.WriteTimeoutSeconds)))
 
 	// CORS for frontend - configurable via ALLOWED_ORIGINS env var
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   config.AllowedOrigins,
+		AllowedOrigins:   config.Current.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
+	// Liveness and readiness probes, unauthenticated for container orchestration
+	r.Get("/healthz", handlers.Healthz)
+	r.Get("/readyz", handlers.Readyz)
+
 	// API routes
 	r.Route("/api", func(r chi.Router) {
 		// CSRF token endpoint (no auth required for initial fetch)
@@ -61,23 +128,33 @@ func main() {
 		r.Group(func(r chi.Router) {
 			// Authentication - check for Cloudflare Access headers
 			// In development, skip auth if DEV_MODE is set
-			if os.Getenv("DEV_MODE") != "true" {
+			if !config.Current.DevMode {
 				r.Use(middleware.CloudflareAuth)
 			}
 			r.Use(middleware.Security)
 
 			// Files
 			r.Get("/files", handlers.ListFiles)
-			r.Post("/files/upload", handlers.UploadFile)
+			r.With(transfer).Post("/files/upload", handlers.UploadFile)
 			r.Post("/files/rename", handlers.RenameFile)
 			r.Post("/files/move", handlers.MoveFile)
-			r.Post("/files/replace", handlers.ReplaceFile)
+			r.Post("/files/copy", handlers.CopyFile)
+			r.With(transfer).Post("/files/replace", handlers.ReplaceFile)
 			r.Post("/files/delete", handlers.DeleteFile)
 			r.Post("/files/mkdir", handlers.CreateDirectory)
-			r.Post("/files/zip", handlers.DownloadZip)
+			r.Post("/files/batch", handlers.BatchFiles)
+			r.With(transfer).Post("/files/extract", handlers.ExtractArchive)
+			r.With(transfer).Post("/files/zip", handlers.DownloadZip)
+			r.Post("/files/zip/jobs", handlers.CreateExportJob)
+			r.Get("/files/zip/job", handlers.GetExportJob)
+			r.Delete("/files/zip/job", handlers.CancelExportJob)
+			r.With(transfer).Get("/files/zip/download", handlers.DownloadExport)
+			r.Get("/files/content", handlers.GetFileContent)
+			r.Put("/files/content", handlers.SaveFileContent)
 
 			// Metadata
 			r.Get("/metadata", handlers.GetMetadata)
+			r.Get("/thumbnail", handlers.GetThumbnail)
 
 			// Logs
 			r.Get("/logs", handlers.GetLogs)
@@ -85,11 +162,56 @@ func main() {
 			// Settings
 			r.Get("/settings", handlers.GetSettings)
 			r.Put("/settings", handlers.UpdateSettings)
+
+			// Image transform presets, changed only by administrators since they
+			// allowlist what the public transform endpoint may render
+			r.Get("/image-presets", handlers.GetImagePresets)
+			r.With(middleware.AdminOnly).Put("/image-presets", handlers.SetImagePreset)
+			r.With(middleware.AdminOnly).Delete("/image-presets", handlers.DeleteImagePreset)
+
+			// Quotas, changed only by administrators so users cannot lift their own
+			r.Get("/quotas", handlers.GetQuotas)
+			r.With(middleware.AdminOnly).Put("/quotas", handlers.SetQuota)
+			r.With(middleware.AdminOnly).Delete("/quotas", handlers.DeleteQuota)
+
+			// Per-directory upload rules, changed only by administrators
+			r.Get("/upload-rules", handlers.GetUploadRules)
+			r.With(middleware.AdminOnly).Put("/upload-rules", handlers.SetUploadRule)
+			r.With(middleware.AdminOnly).Delete("/upload-rules", handlers.DeleteUploadRule)
+
+			// Live file change events (Server-Sent Events)
+			// Streams stay open indefinitely, so they have no write deadline
+			r.With(middleware.Deadlines(seconds(config.Current.ReadTimeoutSeconds), 0)).Get("/events", handlers.StreamEvents)
+
+			// Virus scan history
+			r.Get("/scans", handlers.GetScans)
+
+			// Outgoing webhooks and their delivery history, for administrators only since
+			// they make the server send requests and hand out signing secrets
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.AdminOnly)
+				r.Get("/webhooks", handlers.GetWebhooks)
+				r.Post("/webhooks", handlers.CreateWebhook)
+				r.Put("/webhooks", handlers.UpdateWebhook)
+				r.Delete("/webhooks", handlers.DeleteWebhook)
+				r.Get("/webhooks/deliveries", handlers.GetWebhookDeliveries)
+				r.Post("/webhooks/deliveries/redeliver", handlers.RedeliverWebhook)
+			})
+
+			// Cloudflare cache purge
+			r.Post("/cache/purge", handlers.PurgeCache)
+
+			// Admin-only diagnostics and readiness details
+			r.With(middleware.AdminOnly).Get("/admin/diagnostics", handlers.GetDiagnostics)
+			r.With(middleware.AdminOnly).Get("/admin/readiness", handlers.GetReadiness)
 		})
 	})
 
+	// Public image transforms for CDN consumers, restricted to allowlisted presets
+	r.Get("/transform/*", handlers.TransformImage)
+
 	// Serve static files for frontend in production
-	staticDir := os.Getenv("STATIC_DIR")
+	staticDir := config.Current.StaticDir
 	if staticDir != "" {
 		fs := http.FileServer(http.Dir(staticDir))
 		r.Handle("/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -144,13 +266,93 @@ func main() {
 	}
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	port := config.Current.Port
+
+	// Read and write deadlines are set per route by middleware.Deadlines
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           r,
+		ReadHeaderTimeout: seconds(config.Current.ReadHeaderTimeoutSeconds),
+		IdleTimeout:       seconds(config.Current.IdleTimeoutSeconds),
+	}
+	server.RegisterOnShutdown(handlers.CloseEventStreams)
+
+	reloader, err := setupTLS(server)
+	if err != nil {
+		slog.Error("Failed to configure TLS", "error", err)
+		os.Exit(1)
+	}
+
+	// Redirect plain HTTP to HTTPS when asked to
+	var redirectServer *http.Server
+	if reloader != nil && config.Current.HTTPRedirectAddress != "" {
+		redirectServer = newRedirectServer(config.Current.HTTPRedirectAddress, port)
+		go func() {
+			slog.Info("Redirecting HTTP to HTTPS", "addr", config.Current.HTTPRedirectAddress)
+			if err := redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Redirect server failed", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		var err error
+		if reloader != nil {
+			// HTTP/2 is negotiated automatically over TLS
+			slog.Info("Starting Hextech Control Panel", "port", port, "tls", true,
+				"client_certificates", config.Current.TLSClientCAFile != "")
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			slog.Info("Starting Hextech Control Panel", "port", port)
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdown(server, metricsServer, redirectServer)
+	if reloader != nil {
+		reloader.Stop()
 	}
+}
 
-	log.Printf("Starting Hextech Control Panel on :%s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("Server failed: %v", err)
+// shutdown lets in-flight requests finish within SHUTDOWN_TIMEOUT_SECONDS, then stops
+// background jobs and closes the database. The auxiliary servers, which may be nil,
+// are closed straight away.
+func shutdown(server *http.Server, auxiliary ...*http.Server) {
+	timeout := seconds(config.Current.ShutdownTimeoutSeconds)
+	slog.Info("Shutting down, draining requests", "timeout", timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("Requests still running at the shutdown deadline were cut off", "error", err)
+		server.Close()
+	}
+	for _, s := range auxiliary {
+		if s != nil {
+			s.Close()
+		}
 	}
+
+	handlers.StopBackgroundJobs()
+
+	if err := db.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+	}
+	slog.Info("Shutdown complete")
+}
+
+// seconds converts a configured number of seconds to a duration
+func seconds(n int64) time.Duration {
+	return time.Duration(n) * time.Second
 }
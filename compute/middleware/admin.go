@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"hextech-panel/config"
+)
+
+// AdminOnly restricts routes to the users listed in ADMIN_EMAILS.
+// In development mode, where there is no authenticated user, everyone is allowed.
+func AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.Current.DevMode {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		email := strings.ToLower(GetAuthenticatedEmail(r))
+		if email == "" || !slices.Contains(config.Current.AdminEmails, email) {
+			http.Error(w, `{"error": "Forbidden: administrator access required"}`, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
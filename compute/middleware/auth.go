@@ -2,14 +2,17 @@ package middleware
 
 import (
 	"net/http"
-	"os"
+	"strings"
+
+	"hextech-panel/config"
+	"hextech-panel/logging"
 )
 
 // CloudflareAuth middleware validates Cloudflare Access headers
 func CloudflareAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Bypass Cloudflare auth in development mode
-		if os.Getenv("BYPASS_CF_AUTH") == "true" {
+		if config.Current.BypassCFAuth {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -22,6 +25,9 @@ func CloudflareAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		// Only now is the user known, so only now does it go into the request's log lines
+		logging.SetUser(r.Context(), strings.ToLower(email))
+
 		next.ServeHTTP(w, r)
 	})
 }
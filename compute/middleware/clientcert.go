@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"hextech-panel/config"
+)
+
+// identityHeader carries the authenticated user, set by Cloudflare Access or by ClientCertIdentity
+const identityHeader = "Cf-Access-Authenticated-User-Email"
+
+// ClientCertIdentity authenticates requests by their verified TLS client certificate when
+// TLS_CLIENT_CA_FILE is set. The certificate's identity replaces any identity header the
+// client sent, so without a tunnel in front the header cannot be forged; requests without
+// a certificate are left unauthenticated.
+func ClientCertIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(identityHeader)
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			if identity := certIdentity(r.TLS.VerifiedChains[0][0]); identity != "" {
+				r.Header.Set(identityHeader, identity)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// certIdentity maps a client certificate to a user. A certificate whose email address or
+// common name is listed in TLS_CLIENT_IDENTITIES gets the mapped user; otherwise its first
+// email address is used, then its common name.
+func certIdentity(cert *x509.Certificate) string {
+	cn := strings.ToLower(cert.Subject.CommonName)
+	for _, email := range cert.EmailAddresses {
+		if user, ok := config.Current.TLSClientIdentities[strings.ToLower(email)]; ok {
+			return user
+		}
+	}
+	if user, ok := config.Current.TLSClientIdentities[cn]; ok {
+		return user
+	}
+	if len(cert.EmailAddresses) > 0 {
+		return strings.ToLower(cert.EmailAddresses[0])
+	}
+	return cn
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// Deadlines limits how long a request may spend reading its body and writing its
+// response. An inner Deadlines overrides an outer one, so routes that move large
+// bodies can be given longer than the default. Zero removes the deadline.
+func Deadlines(read, write time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rc := http.NewResponseController(w)
+			now := time.Now()
+			rc.SetReadDeadline(deadline(now, read))
+			rc.SetWriteDeadline(deadline(now, write))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// deadline returns the time d after now, or no deadline for a zero d
+func deadline(now time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return now.Add(d)
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"hextech-panel/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounter("hextech_http_requests_total",
+		"HTTP requests handled, by route pattern, method and status.", "route", "method", "status")
+	httpDuration = metrics.NewHistogram("hextech_http_request_duration_seconds",
+		"Time taken to handle HTTP requests, by route pattern, method and status. Event streams count for as long as they stay open.",
+		metrics.DefBuckets, "route", "method", "status")
+)
+
+// Metrics records the count and latency of every request. Requests are labelled with
+// their route pattern rather than their path so that file names don't become labels.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequests.Inc(route, r.Method, strconv.Itoa(status))
+		httpDuration.ObserveSince(start, route, r.Method, strconv.Itoa(status))
+	})
+}
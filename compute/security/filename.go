@@ -0,0 +1,200 @@
+package security
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrControlCharacter = errors.New("filename contains control characters")
+	ErrHiddenFilename   = errors.New("filename must not start with a dot")
+	ErrReservedFilename = errors.New("filename is reserved")
+	ErrFilenameTooLong  = errors.New("filename is too long")
+	ErrFilenamePolicy   = errors.New("invalid filename policy")
+)
+
+// Filename case modes
+const (
+	CaseLower    = "lower"
+	CasePreserve = "preserve"
+)
+
+// Filename character sets
+const (
+	// CharsetASCII allows letters, digits, dot, dash, underscore, @ and +
+	CharsetASCII = "ascii"
+	// CharsetUnicode normalizes to NFC and additionally allows letters, marks and digits from any script
+	CharsetUnicode = "unicode"
+	// CharsetTransliterate folds accented and special letters to ASCII, then applies CharsetASCII
+	CharsetTransliterate = "transliterate"
+)
+
+// Name changes reported to clients
+const (
+	ChangeLowercased         = "lowercased"
+	ChangeNormalized         = "unicode_normalized"
+	ChangeTransliterated     = "transliterated"
+	ChangeSpacesReplaced     = "spaces_replaced"
+	ChangeCharactersReplaced = "characters_replaced"
+)
+
+// maxFilenameBytes matches NAME_MAX on common filesystems
+const maxFilenameBytes = 255
+
+// FilenamePolicy controls how uploaded and renamed filenames are normalized
+type FilenamePolicy struct {
+	Case    string `json:"case"`
+	Charset string `json:"charset"`
+	// SpaceReplacement replaces each space; empty removes spaces
+	SpaceReplacement string `json:"space_replacement"`
+	// InvalidReplacement replaces each run of other disallowed characters; empty rejects the name
+	InvalidReplacement string `json:"invalid_replacement"`
+}
+
+// DefaultFilenamePolicy lowercases and only allows ASCII, rejecting anything else
+var DefaultFilenamePolicy = FilenamePolicy{
+	Case:             CaseLower,
+	Charset:          CharsetASCII,
+	SpaceReplacement: "-",
+}
+
+// reservedNames are device names Windows refuses regardless of extension
+var reservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true,
+	"com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true,
+	"lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// transliterations covers letters that don't decompose into an ASCII base plus marks
+var transliterations = map[rune]string{
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D",
+	'þ': "th", 'Þ': "Th", 'ł': "l", 'Ł': "L", 'ı': "i", 'ħ': "h", 'Ħ': "H",
+	'ŀ': "l", 'Ŀ': "L", 'ŋ': "n", 'Ŋ': "N", 'ĸ': "k", 'ſ': "s",
+}
+
+// isReplacement reports whether s is an allowed replacement string
+func isReplacement(s string) bool {
+	return s == "" || s == "-" || s == "_"
+}
+
+// Validate checks the policy has known modes and safe replacement characters
+func (p FilenamePolicy) Validate() error {
+	if p.Case != CaseLower && p.Case != CasePreserve {
+		return errors.New("case must be lower or preserve")
+	}
+	if p.Charset != CharsetASCII && p.Charset != CharsetUnicode && p.Charset != CharsetTransliterate {
+		return errors.New("charset must be ascii, unicode or transliterate")
+	}
+	if !isReplacement(p.SpaceReplacement) || !isReplacement(p.InvalidReplacement) {
+		return errors.New("replacement characters must be '-', '_' or empty")
+	}
+	return nil
+}
+
+// allowed reports whether r is in the policy's safe character class
+func (p FilenamePolicy) allowed(r rune) bool {
+	if r == '.' || r == '-' || r == '_' || r == '@' || r == '+' {
+		return true
+	}
+	if r < utf8.RuneSelf {
+		return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9'
+	}
+	return p.Charset == CharsetUnicode && (unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r))
+}
+
+// Normalize applies the policy to a filename. It returns the normalized name and
+// the changes made, in the order they were applied.
+func (p FilenamePolicy) Normalize(filename string) (string, []string, error) {
+	// Remove any path components
+	filename = filepath.Base(filename)
+	if filename == "" || filename == "." || filename == ".." || filename == "/" {
+		return "", nil, ErrInvalidFilename
+	}
+
+	// Control and invisible formatting characters (e.g. bidi overrides) are never replaced
+	for _, r := range filename {
+		if unicode.IsControl(r) || unicode.In(r, unicode.Cf) || r == utf8.RuneError {
+			return "", nil, ErrControlCharacter
+		}
+	}
+	if strings.HasPrefix(filename, ".") {
+		return "", nil, ErrHiddenFilename
+	}
+
+	changes := []string{}
+	apply := func(name, change string) {
+		if name != filename {
+			filename = name
+			changes = append(changes, change)
+		}
+	}
+
+	switch p.Charset {
+	case CharsetUnicode:
+		apply(norm.NFC.String(filename), ChangeNormalized)
+	case CharsetTransliterate:
+		apply(transliterate(filename), ChangeTransliterated)
+	}
+	if p.Case == CaseLower {
+		apply(strings.ToLower(filename), ChangeLowercased)
+	}
+	apply(strings.ReplaceAll(filename, " ", p.SpaceReplacement), ChangeSpacesReplaced)
+
+	var b strings.Builder
+	invalid := false
+	for _, r := range filename {
+		if p.allowed(r) {
+			b.WriteRune(r)
+			invalid = false
+			continue
+		}
+		if p.InvalidReplacement == "" {
+			return "", nil, ErrInvalidFilename
+		}
+		if !invalid {
+			b.WriteString(p.InvalidReplacement)
+		}
+		invalid = true
+	}
+	apply(b.String(), ChangeCharactersReplaced)
+
+	// Replacements and removals must not have produced an empty, hidden or punctuation-led name
+	first, _ := utf8.DecodeRuneInString(filename)
+	if filename == "" || !(unicode.IsLetter(first) || unicode.IsDigit(first)) {
+		return "", nil, ErrInvalidFilename
+	}
+	if len(filename) > maxFilenameBytes {
+		return "", nil, ErrFilenameTooLong
+	}
+	stem, _, _ := strings.Cut(filename, ".")
+	if reservedNames[strings.ToLower(stem)] {
+		return "", nil, ErrReservedFilename
+	}
+
+	return filename, changes, nil
+}
+
+// transliterate folds a name to ASCII where a sensible equivalent exists,
+// leaving characters without one for the charset check
+func transliterate(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if s, ok := transliterations[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
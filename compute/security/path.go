@@ -104,6 +104,26 @@ func GetRelativePath(basePath, fullPath string) string {
 	if err != nil {
 		return fullPath
 	}
+	if rel == "." {
+		return "/"
+	}
 	// Convert to forward slashes for consistency
 	return "/" + strings.ReplaceAll(rel, "\\", "/")
 }
+
+// IsInsideDir reports whether path is dir itself or located beneath it
+func IsInsideDir(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
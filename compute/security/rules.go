@@ -0,0 +1,88 @@
+package security
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrExtensionNotAllowed = errors.New("file extension is not allowed")
+	ErrMIMENotAllowed      = errors.New("file type is not allowed")
+	ErrMIMEBlocked         = errors.New("file type is blocked")
+	ErrFilenamePattern     = errors.New("filename does not match the required pattern")
+)
+
+// ValidateExtensionList checks a filename against an extension allowlist or blocklist.
+// An empty allowlist allows everything.
+func ValidateExtensionList(filename string, exts []string, allow bool) error {
+	if !allow {
+		return ValidateExtension(filename, exts)
+	}
+	if len(exts) == 0 {
+		return nil
+	}
+	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
+	for _, allowed := range exts {
+		if strings.EqualFold(ext, strings.TrimPrefix(allowed, ".")) {
+			return nil
+		}
+	}
+	return ErrExtensionNotAllowed
+}
+
+// DetectMIMETypes returns the base MIME types implied by a filename's extension and
+// sniffed from the start of its content, without duplicates
+func DetectMIMETypes(filename string, head []byte) []string {
+	types := []string{}
+	if byExt := mime.TypeByExtension(filepath.Ext(filename)); byExt != "" {
+		types = append(types, strings.TrimSpace(strings.Split(byExt, ";")[0]))
+	}
+	detected := strings.TrimSpace(strings.Split(http.DetectContentType(head), ";")[0])
+	if len(types) == 0 || types[0] != detected {
+		types = append(types, detected)
+	}
+	return types
+}
+
+// MatchMIME reports whether a MIME type matches a pattern such as "image/png" or "image/*"
+func MatchMIME(pattern, mimeType string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	mimeType = strings.ToLower(mimeType)
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mimeType, prefix+"/")
+	}
+	return pattern == mimeType
+}
+
+// ValidateMIMEList checks a file's MIME types against an allowlist or blocklist.
+// With an allowlist one matching type suffices, since ValidateMIME has already
+// checked the extension and content agree; with a blocklist any match rejects.
+func ValidateMIMEList(types, patterns []string, allow bool) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+	for _, t := range types {
+		for _, p := range patterns {
+			if !MatchMIME(p, t) {
+				continue
+			}
+			if allow {
+				return nil
+			}
+			return ErrMIMEBlocked
+		}
+	}
+	if allow {
+		return ErrMIMENotAllowed
+	}
+	return nil
+}
+
+// CompileFilenamePattern compiles a pattern that must match a whole filename
+func CompileFilenamePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
@@ -8,7 +8,6 @@ import (
 	"mime"
 	"net/http"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
@@ -26,30 +25,6 @@ var DefaultBlockedExtensions = []string{
 	"asp", "aspx", "jsp", "jspx", "cfm", "htaccess",
 }
 
-// filenameRegex allows alphanumeric, dash, underscore, dot
-var filenameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
-
-// ValidateFilename checks if a filename is valid and normalizes it
-func ValidateFilename(filename string) (string, error) {
-	// Remove any path components
-	filename = filepath.Base(filename)
-	
-	if filename == "" || filename == "." || filename == ".." {
-		return "", ErrInvalidFilename
-	}
-
-	// Normalize: lowercase, replace spaces with dashes
-	normalized := strings.ToLower(filename)
-	normalized = strings.ReplaceAll(normalized, " ", "-")
-
-	// Validate against regex
-	if !filenameRegex.MatchString(normalized) {
-		return "", ErrInvalidFilename
-	}
-
-	return normalized, nil
-}
-
 // ValidateExtension checks if the file extension is allowed
 func ValidateExtension(filename string, blockedExtensions []string) error {
 	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
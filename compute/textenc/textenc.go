@@ -0,0 +1,147 @@
+package textenc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Supported encodings
+const (
+	UTF8    = "utf-8"
+	UTF8BOM = "utf-8-bom"
+	UTF16LE = "utf-16le"
+	UTF16BE = "utf-16be"
+	Latin1  = "iso-8859-1"
+)
+
+var (
+	ErrBinary              = errors.New("content appears to be binary")
+	ErrUnsupportedEncoding = errors.New("unsupported text encoding")
+	ErrUnrepresentable     = errors.New("text contains characters the encoding cannot represent")
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Detect guesses the encoding of data from its byte-order mark and contents.
+// Returns ErrBinary for content that does not look like text.
+func Detect(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return UTF8BOM, nil
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return UTF16LE, nil
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return UTF16BE, nil
+	}
+
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", ErrBinary
+	}
+
+	// Control characters other than common whitespace indicate binary data
+	for _, b := range data {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != 0x1B {
+			return "", ErrBinary
+		}
+	}
+
+	if utf8.Valid(data) {
+		return UTF8, nil
+	}
+	return Latin1, nil
+}
+
+// Decode converts data in the given encoding to a UTF-8 string, dropping any byte-order mark
+func Decode(data []byte, enc string) (string, error) {
+	switch enc {
+	case UTF8:
+		if !utf8.Valid(data) {
+			return "", ErrUnsupportedEncoding
+		}
+		return string(data), nil
+	case UTF8BOM:
+		return Decode(bytes.TrimPrefix(data, bomUTF8), UTF8)
+	case UTF16LE, UTF16BE:
+		return decodeUTF16(data, enc), nil
+	case Latin1:
+		var sb strings.Builder
+		sb.Grow(len(data))
+		for _, b := range data {
+			sb.WriteRune(rune(b))
+		}
+		return sb.String(), nil
+	default:
+		return "", ErrUnsupportedEncoding
+	}
+}
+
+// Encode converts a UTF-8 string to the given encoding, adding a byte-order mark where applicable
+func Encode(text string, enc string) ([]byte, error) {
+	if !utf8.ValidString(text) {
+		return nil, ErrUnrepresentable
+	}
+
+	switch enc {
+	case UTF8:
+		return []byte(text), nil
+	case UTF8BOM:
+		return append(append([]byte{}, bomUTF8...), text...), nil
+	case UTF16LE, UTF16BE:
+		return encodeUTF16(text, enc), nil
+	case Latin1:
+		out := make([]byte, 0, len(text))
+		for _, r := range text {
+			if r > 0xFF {
+				return nil, ErrUnrepresentable
+			}
+			out = append(out, byte(r))
+		}
+		return out, nil
+	default:
+		return nil, ErrUnsupportedEncoding
+	}
+}
+
+// decodeUTF16 decodes UTF-16 data with an optional leading byte-order mark
+func decodeUTF16(data []byte, enc string) string {
+	var order binary.ByteOrder = binary.LittleEndian
+	bom := bomUTF16LE
+	if enc == UTF16BE {
+		order, bom = binary.BigEndian, bomUTF16BE
+	}
+	data = bytes.TrimPrefix(data, bom)
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, order.Uint16(data[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// encodeUTF16 encodes text as UTF-16 with a leading byte-order mark
+func encodeUTF16(text string, enc string) []byte {
+	bom := bomUTF16LE
+	if enc == UTF16BE {
+		bom = bomUTF16BE
+	}
+
+	units := utf16.Encode([]rune(text))
+	out := make([]byte, len(bom), len(bom)+len(units)*2)
+	copy(out, bom)
+	for _, u := range units {
+		if enc == UTF16BE {
+			out = binary.BigEndian.AppendUint16(out, u)
+		} else {
+			out = binary.LittleEndian.AppendUint16(out, u)
+		}
+	}
+	return out
+}
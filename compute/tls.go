@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"hextech-panel/certs"
+	"hextech-panel/config"
+)
+
+// setupTLS configures server for HTTPS and HTTP/2 when TLS_CERT_FILE and TLS_KEY_FILE are
+// set, with client certificate verification when TLS_CLIENT_CA_FILE is set. It returns
+// nil when TLS is not configured.
+func setupTLS(server *http.Server) (*certs.Reloader, error) {
+	// The settings were validated together when the configuration was loaded
+	if config.Current.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	var clientAuth tls.ClientAuthType
+	switch config.Current.TLSClientAuth {
+	case "require":
+		clientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		clientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("invalid TLS_CLIENT_AUTH %q: use require or optional", config.Current.TLSClientAuth)
+	}
+
+	reloader, err := certs.NewReloader(config.Current.TLSCertFile, config.Current.TLSKeyFile, config.Current.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	server.TLSConfig = reloader.TLSConfig(clientAuth)
+
+	// Reload straight away on SIGHUP instead of waiting for the change to be noticed
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reloader.Reload(); err != nil {
+				slog.Error("Failed to reload TLS certificate, keeping the current one", "error", err)
+			}
+		}
+	}()
+
+	return reloader, nil
+}
+
+// newRedirectServer answers plain HTTP requests on addr with a permanent redirect to
+// the same URL over HTTPS on tlsPort
+func newRedirectServer(addr, tlsPort string) *http.Server {
+	return &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+			}
+			if host == "" {
+				http.Error(w, "Host header required", http.StatusBadRequest)
+				return
+			}
+			switch {
+			case tlsPort != "443":
+				host = net.JoinHostPort(host, tlsPort)
+			case strings.Contains(host, ":"):
+				host = "[" + host + "]"
+			}
+			// 308 keeps the method and body, unlike 301
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+		ReadHeaderTimeout: seconds(config.Current.ReadHeaderTimeoutSeconds),
+		ReadTimeout:       seconds(config.Current.ReadTimeoutSeconds),
+		WriteTimeout:      seconds(config.Current.WriteTimeoutSeconds),
+		IdleTimeout:       seconds(config.Current.IdleTimeoutSeconds),
+	}
+}
@@ -0,0 +1,272 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"hextech-panel/db"
+)
+
+const (
+	// retryBase is the delay before the first retry; each further retry doubles it
+	retryBase = 30 * time.Second
+	// retryMax caps the delay between retries
+	retryMax = 6 * time.Hour
+	// batchSize is how many due deliveries are loaded at a time
+	batchSize = 20
+	// maxErrorLen bounds the response excerpt stored with a failed attempt
+	maxErrorLen = 512
+)
+
+// Config configures a webhook dispatcher
+type Config struct {
+	// MaxAttempts is how many times a delivery is tried before it is marked failed
+	MaxAttempts int
+	// Timeout bounds a single delivery attempt
+	Timeout time.Duration
+	// PollInterval is how often the queue is checked for retries that became due
+	PollInterval time.Duration
+	// AllowedNetworks are internal networks that deliveries may nonetheless reach
+	AllowedNetworks []netip.Prefix
+}
+
+// Dispatcher delivers queued webhook events in the background. The queue lives in
+// SQLite, so deliveries pending when the process stops resume on the next start.
+type Dispatcher struct {
+	cfg    Config
+	client *http.Client
+	wake   chan struct{}
+	ctx    context.Context
+	stop   context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher and starts delivering
+func NewDispatcher(cfg Config) *Dispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				// No proxy: the guard must see the receiver's address, not a proxy's
+				DialContext: (&net.Dialer{
+					Timeout:   cfg.Timeout,
+					KeepAlive: 30 * time.Second,
+					Control:   guardDial(cfg.AllowedNetworks),
+				}).DialContext,
+				ForceAttemptHTTP2:     true,
+				MaxIdleConns:          10,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ExpectContinueTimeout: time.Second,
+			},
+			// A redirect is treated as a failed delivery rather than followed with the signed payload
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		wake: make(chan struct{}, 1),
+		ctx:  ctx,
+		stop: stop,
+	}
+
+	d.wg.Add(1)
+	go d.loop()
+	return d
+}
+
+// Publish queues an event for every active webhook subscribed to it
+func (d *Dispatcher) Publish(e Event) error {
+	hooks, err := db.GetWebhooks()
+	if err != nil {
+		return err
+	}
+
+	e.ID = newEventID()
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	queued := false
+	for _, h := range hooks {
+		if !h.Active || !Matches(h.Events, h.PathPrefixes, e) {
+			continue
+		}
+		if err := db.EnqueueDelivery(h.ID, e.ID, e.Type, string(payload)); err != nil {
+			return err
+		}
+		queued = true
+	}
+	if queued {
+		d.Wake()
+	}
+	return nil
+}
+
+// Wake makes the dispatcher check the queue now instead of at its next poll
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Shutdown stops delivering and waits for an attempt in progress to finish
+func (d *Dispatcher) Shutdown() {
+	d.stop()
+	d.wg.Wait()
+}
+
+// loop delivers due events whenever woken and on every poll
+func (d *Dispatcher) loop() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue()
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue attempts every delivery that is currently due
+func (d *Dispatcher) deliverDue() {
+	for d.ctx.Err() == nil {
+		due, err := db.GetDueDeliveries(batchSize)
+		if err != nil {
+			slog.Error("Failed to load webhook deliveries", "error", err)
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+		progressed := false
+		for _, delivery := range due {
+			if d.ctx.Err() != nil {
+				return
+			}
+			if d.attempt(delivery) {
+				progressed = true
+			}
+		}
+		// Deliveries whose outcome could not be recorded are still due; leave them
+		// for the next poll rather than loading the same batch again straight away
+		if !progressed {
+			return
+		}
+	}
+}
+
+// attempt sends a delivery once and records the outcome, reporting whether it was recorded
+func (d *Dispatcher) attempt(delivery db.WebhookDelivery) bool {
+	hook, err := db.GetWebhook(delivery.WebhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d.record(delivery, db.DeliveryFailed, 0, "webhook no longer exists", 0)
+	}
+	if err != nil {
+		slog.Error("Failed to load webhook", "webhook_id", delivery.WebhookID, "error", err)
+		return false
+	}
+
+	status, retryAfter, err := d.send(hook, delivery)
+	if err == nil {
+		return d.record(delivery, db.DeliveryDelivered, status, "", 0)
+	}
+	if d.ctx.Err() != nil {
+		// Interrupted by shutdown; the delivery stays due and is retried on the next start
+		return false
+	}
+
+	if delivery.Attempts+1 >= d.cfg.MaxAttempts {
+		return d.record(delivery, db.DeliveryFailed, status, err.Error(), 0)
+	}
+	return d.record(delivery, db.DeliveryPending, status, err.Error(), max(backoff(delivery.Attempts+1), retryAfter))
+}
+
+// record stores the outcome of an attempt, reporting whether it succeeded
+func (d *Dispatcher) record(delivery db.WebhookDelivery, status string, responseStatus int, lastError string, retry time.Duration) bool {
+	if err := db.RecordDeliveryAttempt(delivery.ID, status, responseStatus, lastError, int64(retry/time.Second)); err != nil {
+		slog.Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+		return false
+	}
+	return true
+}
+
+// send posts a signed delivery, returning the response status and, when the receiver
+// asked for it via Retry-After, how long to wait before trying again
+func (d *Dispatcher) send(hook db.Webhook, delivery db.WebhookDelivery) (int, time.Duration, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "hextech-panel-webhook")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.EventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, now, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorLen))
+		return resp.StatusCode, 0, nil
+	}
+
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLen))
+	var retryAfter time.Duration
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		retryAfter = min(time.Duration(secs)*time.Second, retryMax)
+	}
+	msg := resp.Status
+	if len(bytes.TrimSpace(excerpt)) > 0 {
+		msg += ": " + string(bytes.TrimSpace(excerpt))
+	}
+	return resp.StatusCode, retryAfter, errors.New(msg)
+}
+
+// backoff returns the delay before retrying after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	delay := retryBase
+	for i := 1; i < attempts && delay < retryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMax)
+}
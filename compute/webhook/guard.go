@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ParseNetworks parses CIDR ranges and single addresses, as listed in
+// WEBHOOK_ALLOWED_NETWORKS
+func ParseNetworks(list []string) ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if prefix, err := netip.ParsePrefix(s); err == nil {
+			networks = append(networks, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", s)
+		}
+		networks = append(networks, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return networks, nil
+}
+
+// internal reports whether addr belongs to the host or a private network, which
+// webhooks must not reach unless allowed: loopback, private, link-local,
+// unspecified and multicast addresses
+func internal(addr netip.Addr) bool {
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsUnspecified() || addr.IsMulticast()
+}
+
+// guardDial returns a dialer control function that refuses connections to internal
+// addresses outside allowed. It checks the address actually dialed, after DNS
+// resolution, so a hostname cannot be pointed at an internal address later.
+func guardDial(allowed []netip.Prefix) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return err
+		}
+		addr = addr.Unmap().WithZone("")
+		if !internal(addr) {
+			return nil
+		}
+		for _, network := range allowed {
+			if network.Contains(addr) {
+				return nil
+			}
+		}
+		return fmt.Errorf("webhook destination %s is an internal address; add it to WEBHOOK_ALLOWED_NETWORKS to allow it", addr)
+	}
+}
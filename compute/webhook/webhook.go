@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event types
+const (
+	EventUpload  = "upload"
+	EventReplace = "replace"
+	EventDelete  = "delete"
+	EventMove    = "move"
+	EventRename  = "rename"
+	EventMkdir   = "mkdir"
+	EventCopy    = "copy"
+)
+
+// Events lists every event type a webhook can subscribe to
+var Events = []string{EventUpload, EventReplace, EventDelete, EventMove, EventRename, EventMkdir, EventCopy}
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Event describes a change to a file or directory
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"event"`
+	// Path is the affected path relative to the CDN root; for move, rename and
+	// copy it is the new path and OldPath is where it came from
+	Path      string    `json:"path"`
+	OldPath   string    `json:"old_path,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	IsDir     bool      `json:"is_dir"`
+	Actor     string    `json:"actor,omitempty"`
+	SourceIP  string    `json:"source_ip,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ValidEvent reports whether t is a known event type
+func ValidEvent(t string) bool {
+	for _, e := range Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newEventID returns a random event identifier, shared by every delivery of an event
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Sign computes the signature header value for a payload sent at the given time.
+// Receivers recompute HMAC-SHA256(secret, timestamp + "." + body) and compare it
+// in constant time, rejecting stale timestamps to prevent replays.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Matches reports whether a webhook subscribed to events under prefixes receives
+// an event. Empty lists match everything; a move matches on either of its paths.
+func Matches(events, prefixes []string, e Event) bool {
+	if len(events) > 0 {
+		found := false
+		for _, t := range events {
+			if t == e.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if underPrefix(prefix, e.Path) || (e.OldPath != "" && underPrefix(prefix, e.OldPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// underPrefix reports whether relPath is prefix itself or located beneath it
+func underPrefix(prefix, relPath string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return relPath == prefix || strings.HasPrefix(relPath, prefix+"/")
+}
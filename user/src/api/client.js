@@ -32,11 +32,19 @@ export const filesApi = {
     list: (path = '/', sort = 'name', dir = 'asc') =>
         api.get('/files', { params: { path, sort, dir } }),
 
-    upload: (file, directory = '/', overwrite = false, onProgress) => {
+    // conflict: true/false to overwrite, or an on_conflict strategy such as 'keep_both'
+    upload: (file, directory = '/', conflict = false, onProgress) => {
         const formData = new FormData();
         formData.append('file', file);
         formData.append('directory', directory);
-        formData.append('overwrite', overwrite.toString());
+        if (typeof conflict === 'string') {
+            formData.append('on_conflict', conflict);
+        } else {
+            formData.append('overwrite', conflict.toString());
+        }
+        if (file.lastModified) {
+            formData.append('last_modified', file.lastModified);
+        }
 
         return api.post('/files/upload', formData, {
             headers: { 'Content-Type': 'multipart/form-data' },
@@ -49,11 +57,56 @@ export const filesApi = {
         });
     },
 
-    rename: (path, newName) =>
-        api.post('/files/rename', { path, new_name: newName }),
+    // Uploads several files in one request; entries are File objects or
+    // { file, relativePath } pairs, e.g. from a folder picker's webkitRelativePath
+    uploadMany: (entries, directory = '/', conflict = 'fail', onProgress) => {
+        const formData = new FormData();
+        formData.append('directory', directory);
+        formData.append('on_conflict', conflict);
+        const withPaths = entries.some(e => e.relativePath);
+        for (const entry of entries) {
+            const file = entry.file || entry;
+            formData.append('file', file);
+            if (withPaths) {
+                formData.append('relative_path', entry.relativePath || file.name);
+            }
+            formData.append('last_modified', file.lastModified || Date.now());
+        }
+
+        return api.post('/files/upload', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+            onUploadProgress: (progressEvent) => {
+                if (onProgress) {
+                    const percent = Math.round((progressEvent.loaded * 100) / progressEvent.total);
+                    onProgress(percent);
+                }
+            }
+        });
+    },
 
-    move: (path, destination) =>
-        api.post('/files/move', { path, destination }),
+    extract: (file, directory = '/', overwrite = false, onProgress) => {
+        const formData = new FormData();
+        formData.append('file', file);
+        formData.append('directory', directory);
+        formData.append('overwrite', overwrite.toString());
+
+        return api.post('/files/extract', formData, {
+            headers: { 'Content-Type': 'multipart/form-data' },
+            onUploadProgress: (progressEvent) => {
+                if (onProgress) {
+                    const percent = Math.round((progressEvent.loaded * 100) / progressEvent.total);
+                    onProgress(percent);
+                }
+            }
+        });
+    },
+
+    // onConflict: 'fail' | 'skip' | 'overwrite' | 'keep_both' | 'overwrite_if_newer' | 'overwrite_if_different'
+    rename: (path, newName, onConflict = 'fail') =>
+        api.post('/files/rename', { path, new_name: newName, on_conflict: onConflict }),
+
+    move: (path, destination, onConflict = 'fail') =>
+        api.post('/files/move', { path, destination, on_conflict: onConflict }),
 
     replace: (path, file, onProgress) => {
         const formData = new FormData();
@@ -74,23 +127,44 @@ export const filesApi = {
     delete: (path, confirmFilename) =>
         api.post('/files/delete', { path, confirm_filename: confirmFilename }),
 
+    // onConflict: 'fail' | 'skip' | 'overwrite' | 'keep_both'; omit destination to duplicate in place
+    copy: (path, destination = '', { newName = '', onConflict = 'fail' } = {}) =>
+        api.post('/files/copy', { path, destination, new_name: newName, on_conflict: onConflict }),
+
+    // operations: [{ op: 'move' | 'copy' | 'delete' | 'rename' | 'mkdir', path, destination, new_name, name }]
+    batch: (operations, { dryRun = false, atomic = false } = {}) =>
+        api.post('/files/batch', { operations, dry_run: dryRun, atomic }),
+
     mkdir: (path, name) =>
         api.post('/files/mkdir', { path, name }),
 
     getMetadata: (path) =>
         api.get('/metadata', { params: { path } }),
 
-    downloadZip: async (paths) => {
-        const response = await api.post('/files/zip', { paths }, {
+    getContent: (path) =>
+        api.get('/files/content', { params: { path } }),
+
+    saveContent: (path, content, sha256, encoding = 'utf-8') =>
+        api.put('/files/content', { path, content, encoding }, {
+            headers: { 'If-Match': `"${sha256}"` }
+        }),
+
+    thumbnailUrl: (path, size = 256) =>
+        `/api/thumbnail?path=${encodeURIComponent(path)}&size=${size}`,
+
+    // options: { format: 'zip' | 'tar.gz' | 'tar.zst', level: 0-9, checksums: bool }
+    downloadZip: async (paths, options = {}) => {
+        const response = await api.post('/files/zip', { paths, ...options }, {
             responseType: 'blob'
         });
         // Trigger download
         const url = window.URL.createObjectURL(response.data);
         const a = document.createElement('a');
         a.href = url;
+        const ext = '.' + (options.format || 'zip');
         const filename = paths.length === 1
-            ? paths[0].split('/').pop() + '.zip'
-            : 'download.zip';
+            ? paths[0].split('/').pop() + ext
+            : 'download' + ext;
         a.download = filename;
         document.body.appendChild(a);
         a.click();
@@ -100,6 +174,14 @@ export const filesApi = {
     }
 };
 
+// Archive export jobs API, for archives too large to stream in one request
+export const exportJobsApi = {
+    create: (paths, options = {}) => api.post('/files/zip/jobs', { paths, ...options }),
+    status: (id) => api.get('/files/zip/job', { params: { id } }),
+    cancel: (id) => api.delete('/files/zip/job', { params: { id } }),
+    downloadUrl: (id) => `/api/files/zip/download?id=${encodeURIComponent(id)}`
+};
+
 // Logs API
 export const logsApi = {
     list: (limit = 50, offset = 0) =>
@@ -112,4 +194,63 @@ export const settingsApi = {
     update: (settings) => api.put('/settings', settings)
 };
 
+// Image transform presets API
+export const imagePresetsApi = {
+    list: () => api.get('/image-presets'),
+    save: (preset) => api.put('/image-presets', preset),
+    delete: (name) => api.delete('/image-presets', { params: { name } })
+};
+
+// Quotas API
+export const quotasApi = {
+    list: () => api.get('/quotas'),
+    save: (scope, target, maxBytes = 0, maxFiles = 0) =>
+        api.put('/quotas', { scope, target, max_bytes: maxBytes, max_files: maxFiles }),
+    delete: (id) => api.delete('/quotas', { params: { id } })
+};
+
+// Per-directory upload rules API
+// rule: { target, extension_mode: 'allow' | 'block', extensions, mime_mode: 'allow' | 'block', mime_types, max_size, filename_pattern }
+export const uploadRulesApi = {
+    list: () => api.get('/upload-rules'),
+    save: (rule) => api.put('/upload-rules', rule),
+    delete: (id) => api.delete('/upload-rules', { params: { id } })
+};
+
+// Virus scan results API
+export const scansApi = {
+    list: (limit = 50, offset = 0, status = '') =>
+        api.get('/scans', { params: { limit, offset, status } })
+};
+
+// Webhooks API
+export const webhooksApi = {
+    list: () => api.get('/webhooks'),
+    create: (webhook) => api.post('/webhooks', webhook),
+    update: (id, webhook) => api.put('/webhooks', webhook, { params: { id } }),
+    delete: (id) => api.delete('/webhooks', { params: { id } }),
+    deliveries: (webhookId = 0, limit = 50, offset = 0, status = '') =>
+        api.get('/webhooks/deliveries', { params: { webhook_id: webhookId, limit, offset, status } }),
+    redeliver: (id) => api.post('/webhooks/deliveries/redeliver', null, { params: { id } })
+};
+
+// Cloudflare cache purge API
+export const cacheApi = {
+    purge: (paths) => api.post('/cache/purge', { paths })
+};
+
+// Live file change events; EventSource reconnects and resumes on its own
+export const eventsApi = {
+    subscribe: (paths = ['/']) => {
+        const params = new URLSearchParams();
+        paths.forEach((p) => params.append('path', p));
+        return new EventSource(`/api/events?${params}`, { withCredentials: true });
+    }
+};
+
+// Admin diagnostics
+export const adminApi = {
+    getDiagnostics: () => api.get('/admin/diagnostics')
+};
+
 export default api;
@@ -285,10 +285,14 @@ function FilesPage() {
     const handleBulkDelete = async () => {
         setIsBulkDeleting(true)
         try {
-            for (const file of selectedFiles) {
-                await filesApi.delete(file.path, file.name)
+            const operations = selectedFiles.map(f => ({ op: 'delete', path: f.path }))
+            const response = await filesApi.batch(operations)
+            const { succeeded, failed } = response.data
+            if (failed > 0) {
+                toast.error(`${failed} item${failed !== 1 ? 's' : ''} could not be deleted`)
+            } else {
+                toast.success(`${succeeded} item${succeeded !== 1 ? 's' : ''} deleted`)
             }
-            toast.success(`${selectedFiles.length} item${selectedFiles.length !== 1 ? 's' : ''} deleted`)
             handleClearSelection()
             setShowBulkDelete(false)
             setShowDeleteModal(false)
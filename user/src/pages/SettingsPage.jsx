@@ -17,7 +17,8 @@ function SettingsPage() {
         base_directory: '',
         max_upload_size: 0,
         blocked_extensions: [],
-        public_hostname: ''
+        public_hostname: '',
+        filename_policy: { case: 'lower', charset: 'ascii', space_replacement: '-', invalid_replacement: '' }
     })
     const [loading, setLoading] = useState(true)
     const [saving, setSaving] = useState(false)
@@ -56,7 +57,8 @@ function SettingsPage() {
                 base_directory: settings.base_directory,
                 max_upload_size: settings.max_upload_size,
                 blocked_extensions: settings.blocked_extensions,
-                public_hostname: settings.public_hostname
+                public_hostname: settings.public_hostname,
+                filename_policy: settings.filename_policy
             })
 
             toast.success('Settings saved successfully')
@@ -69,6 +71,21 @@ function SettingsPage() {
     }
 
 
+    const setFilenamePolicy = (field, value) =>
+        setSettings({ ...settings, filename_policy: { ...settings.filename_policy, [field]: value } })
+
+    const selectStyle = {
+        marginTop: 6,
+        width: '100%',
+        height: 36,
+        padding: '0 10px',
+        borderRadius: 6,
+        border: `1px solid ${borderColor}`,
+        backgroundColor: bgColor,
+        color: textColor,
+        fontSize: 14
+    }
+
     const formatBytes = (bytes) => {
         const mb = bytes / (1024 * 1024)
         return `${mb.toFixed(0)} MB`
@@ -408,6 +425,54 @@ function SettingsPage() {
                                 <p style={{ fontSize: 12, color: mutedText, marginTop: 4 }}>Maximum file size in bytes</p>
                             </div>
 
+                            <div>
+                                <Label style={{ color: textColor }}>Filename Policy</Label>
+                                <div style={{ display: 'grid', gridTemplateColumns: '1fr 1fr', gap: 12 }}>
+                                    <select
+                                        aria-label="Letter case"
+                                        value={settings.filename_policy.case}
+                                        onChange={(e) => setFilenamePolicy('case', e.target.value)}
+                                        style={selectStyle}
+                                    >
+                                        <option value="lower">Lowercase names</option>
+                                        <option value="preserve">Preserve case</option>
+                                    </select>
+                                    <select
+                                        aria-label="Characters"
+                                        value={settings.filename_policy.charset}
+                                        onChange={(e) => setFilenamePolicy('charset', e.target.value)}
+                                        style={selectStyle}
+                                    >
+                                        <option value="ascii">ASCII only</option>
+                                        <option value="transliterate">Transliterate to ASCII</option>
+                                        <option value="unicode">Unicode (NFC)</option>
+                                    </select>
+                                    <select
+                                        aria-label="Space replacement"
+                                        value={settings.filename_policy.space_replacement}
+                                        onChange={(e) => setFilenamePolicy('space_replacement', e.target.value)}
+                                        style={selectStyle}
+                                    >
+                                        <option value="-">Spaces become "-"</option>
+                                        <option value="_">Spaces become "_"</option>
+                                        <option value="">Remove spaces</option>
+                                    </select>
+                                    <select
+                                        aria-label="Invalid character replacement"
+                                        value={settings.filename_policy.invalid_replacement}
+                                        onChange={(e) => setFilenamePolicy('invalid_replacement', e.target.value)}
+                                        style={selectStyle}
+                                    >
+                                        <option value="">Reject other characters</option>
+                                        <option value="-">Replace others with "-"</option>
+                                        <option value="_">Replace others with "_"</option>
+                                    </select>
+                                </div>
+                                <p style={{ fontSize: 12, color: mutedText, marginTop: 4 }}>
+                                    How uploaded and renamed filenames are normalized. Control characters, reserved names and leading dots are always rejected.
+                                </p>
+                            </div>
+
                             <div>
                                 {/* Label with security icon */}
                                 <div style={{ display: 'flex', alignItems: 'center', gap: 8, marginBottom: 8 }}>